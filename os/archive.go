@@ -0,0 +1,287 @@
+package os
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+)
+
+// archivePath returns the path in the filesystem of an archive entry to be
+// extracted into dir, which cannot be outside of dir.
+func archivePath(dir, name string) string {
+	return path.Join(dir, path.Clean("/"+name))
+}
+
+// LoadTar extracts the tar archive read from r into the directory dir, which
+// is created if it does not exist. Archives compressed with gzip are detected
+// and decompressed.
+//
+// Directories, regular files, symbolic links and hard links are extracted,
+// along with their modes and modification times; other entries are skipped.
+func (f *FS) LoadTar(r io.Reader, dir string) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	} else {
+		r = br
+	}
+	if err := f.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	var dirs dirAttrs
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		p := archivePath(dir, hdr.Name)
+		fi := hdr.FileInfo()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := f.MkdirAll(p, 0700); err != nil {
+				return err
+			}
+			dirs.add(p, fi)
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			f.WriteBytes(p, fi.Mode()&hostModeMask, data)
+			if err := f.Chtimes(p, hdr.ModTime, hdr.ModTime); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := f.MkdirAll(path.Dir(p), 0777); err != nil {
+				return err
+			}
+			if err := f.Symlink(hdr.Linkname, p); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			if err := f.MkdirAll(path.Dir(p), 0777); err != nil {
+				return err
+			}
+			if err := f.Link(archivePath(dir, hdr.Linkname), p); err != nil {
+				return err
+			}
+		}
+	}
+	return dirs.apply(f)
+}
+
+// LoadZip extracts the zip archive of the given size read from r into the
+// directory dir, which is created if it does not exist.
+//
+// Directories, regular files and symbolic links are extracted, along with
+// their modes and modification times; other entries are skipped.
+func (f *FS) LoadZip(r io.ReaderAt, size int64, dir string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	if err := f.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	var dirs dirAttrs
+	for _, zf := range zr.File {
+		p := archivePath(dir, zf.Name)
+		fi := zf.FileInfo()
+		mode := fi.Mode()
+		if mode.IsDir() {
+			if err := f.MkdirAll(p, 0700); err != nil {
+				return err
+			}
+			dirs.add(p, fi)
+			continue
+		}
+		if mode&os.ModeSymlink == 0 && !mode.IsRegular() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if mode&os.ModeSymlink != 0 {
+			if err := f.MkdirAll(path.Dir(p), 0777); err != nil {
+				return err
+			}
+			if err := f.Symlink(string(data), p); err != nil {
+				return err
+			}
+			continue
+		}
+		f.WriteBytes(p, mode&hostModeMask, data)
+		if err := f.Chtimes(p, zf.Modified, zf.Modified); err != nil {
+			return err
+		}
+	}
+	return dirs.apply(f)
+}
+
+// walkFiles calls fn for each of the descendants of the directory dir, in
+// lexical order and without following symbolic links, with the path of each
+// relative to dir.
+func (f *FS) walkFiles(dir, rel string, fn func(string, os.FileInfo) error) error {
+	d, err := f.Open(path.Join(dir, rel))
+	if err != nil {
+		return err
+	}
+	fis, err := d.Readdir(-1)
+	d.Close()
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		name := path.Join(rel, fi.Name())
+		if err := fn(name, fi); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if err := f.walkFiles(dir, name, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DumpTar writes the contents of the directory dir to w as a tar archive, with
+// paths relative to dir. Files linked under several names are written once,
+// with the other names as hard links.
+func (f *FS) DumpTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	links := make(map[os.FileInfo]string)
+	err := f.walkFiles(dir, "", func(name string, fi os.FileInfo) error {
+		var target string
+		mode := fi.Mode()
+		if mode&os.ModeSymlink != 0 {
+			var err error
+			if target, err = f.Readlink(path.Join(dir, name)); err != nil {
+				return err
+			}
+		} else if !mode.IsRegular() && !mode.IsDir() {
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(tarInfo{fi}, target)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		hdr.Uid, hdr.Gid = ownerOf(fi)
+		if mode.IsDir() {
+			hdr.Name += "/"
+		} else if mode.IsRegular() {
+			n := nodeOf(fi)
+			if first, ok := links[n]; ok {
+				hdr.Typeflag = tar.TypeLink
+				hdr.Linkname = first
+				hdr.Size = 0
+			} else {
+				links[n] = name
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			return f.copyTo(tw, path.Join(dir, name))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// DumpZip writes the contents of the directory dir to w as a zip archive, with
+// paths relative to dir.
+func (f *FS) DumpZip(w io.Writer, dir string) error {
+	zw := zip.NewWriter(w)
+	err := f.walkFiles(dir, "", func(name string, fi os.FileInfo) error {
+		mode := fi.Mode()
+		if !mode.IsRegular() && !mode.IsDir() && mode&os.ModeSymlink == 0 {
+			return nil
+		}
+		hdr, err := zip.FileInfoHeader(fi)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if mode.IsDir() {
+			hdr.Name += "/"
+		} else if mode.IsRegular() {
+			hdr.Method = zip.Deflate
+		}
+		zf, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		switch {
+		case mode&os.ModeSymlink != 0:
+			target, err := f.Readlink(path.Join(dir, name))
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(zf, target)
+			return err
+		case mode.IsRegular():
+			return f.copyTo(zf, path.Join(dir, name))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// copyTo writes the contents of the file at p to w.
+func (f *FS) copyTo(w io.Writer, p string) error {
+	fl, err := f.Open(p)
+	if err != nil {
+		return err
+	}
+	defer fl.Close()
+	_, err = io.Copy(w, fl)
+	return err
+}
+
+// tarInfo hides the Sys value of a FileInfo from tar.FileInfoHeader, which
+// would otherwise look up the names of its owners on the host.
+type tarInfo struct {
+	os.FileInfo
+}
+
+func (tarInfo) Sys() interface{} {
+	return nil
+}
+
+// ownerOf returns the owner uid and gid of a FileInfo returned by the package.
+func ownerOf(fi os.FileInfo) (int, int) {
+	type i interface {
+		ownership() (int, int)
+	}
+	if o, ok := nodeOf(fi).(i); ok {
+		return o.ownership()
+	}
+	return 0, 0
+}
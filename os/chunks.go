@@ -0,0 +1,209 @@
+package os
+
+// chunkSize is the size of the blocks in which the contents of files are
+// stored.
+const chunkSize = 4096
+
+// chunks stores the contents of a file as a sparse set of fixed size blocks,
+// with missing blocks, and any part of a block past its length, reading as
+// zeros.
+//
+// The methods of chunks must be called with the lock of the owning bfile held.
+type chunks struct {
+	size int64
+	data map[int64][]byte
+
+	// owned records the blocks that may be written to in place; when nil,
+	// all blocks may be.
+	owned map[int64]bool
+
+	// shared is set when data is also referenced elsewhere, such as by a
+	// Snapshot, and so must be copied before being changed.
+	shared bool
+}
+
+// newChunks creates chunks containing b without copying it. If owned is false,
+// each block of b is copied before being written to.
+func newChunks(b []byte, owned bool) chunks {
+	c := chunks{
+		size: int64(len(b)),
+		data: make(map[int64][]byte, (len(b)+chunkSize-1)/chunkSize),
+	}
+	if !owned {
+		c.owned = make(map[int64]bool)
+	}
+	for i := int64(0); len(b) > 0; i++ {
+		n := chunkSize
+		if n > len(b) {
+			n = len(b)
+		}
+		c.data[i] = b[:n:n]
+		b = b[n:]
+	}
+	return c
+}
+
+// allocated returns the number of bytes of storage used by the blocks.
+func (c *chunks) allocated() int64 {
+	return int64(len(c.data)) * chunkSize
+}
+
+// has returns true if block i has storage allocated.
+func (c *chunks) has(i int64) bool {
+	_, ok := c.data[i]
+	return ok
+}
+
+// allocatedSize returns the number of bytes of storage used by contents of
+// the given size with no holes.
+func allocatedSize(size int64) int64 {
+	return (size + chunkSize - 1) / chunkSize * chunkSize
+}
+
+// readAt reads into p from the given offset, returning the number of bytes
+// read, which is only less than len(p) at the end of the contents.
+func (c *chunks) readAt(p []byte, off int64) int {
+	if off >= c.size {
+		return 0
+	}
+	if rem := c.size - off; int64(len(p)) > rem {
+		p = p[:rem]
+	}
+	n := len(p)
+	for len(p) > 0 {
+		i, o := off/chunkSize, int(off%chunkSize)
+		l := chunkSize - o
+		if l > len(p) {
+			l = len(p)
+		}
+		var m int
+		if b := c.data[i]; o < len(b) {
+			m = copy(p[:l], b[o:])
+		}
+		for ; m < l; m++ {
+			p[m] = 0
+		}
+		p = p[l:]
+		off += int64(l)
+	}
+	return n
+}
+
+// writeAt writes p at the given offset, extending the contents if needed.
+func (c *chunks) writeAt(p []byte, off int64) {
+	c.unshare()
+	if end := off + int64(len(p)); end > c.size {
+		c.size = end
+	}
+	for len(p) > 0 {
+		i, o := off/chunkSize, int(off%chunkSize)
+		l := chunkSize - o
+		if l > len(p) {
+			l = len(p)
+		}
+		copy(c.block(i, o+l)[o:], p[:l])
+		p = p[l:]
+		off += int64(l)
+	}
+}
+
+// block returns block i, ready to be written to and with a length of at least
+// l.
+func (c *chunks) block(i int64, l int) []byte {
+	if c.data == nil {
+		c.data = make(map[int64][]byte)
+	}
+	b := c.data[i]
+	if c.owned != nil {
+		if !c.owned[i] {
+			b = append([]byte(nil), b...)
+			c.owned[i] = true
+		}
+	}
+	if len(b) < l {
+		b = append(b, make([]byte, l-len(b))...)
+	}
+	c.data[i] = b
+	return b
+}
+
+// truncate changes the size of the contents, removing any blocks past the new
+// size. Growing the contents allocates nothing.
+func (c *chunks) truncate(size int64) {
+	if size < c.size {
+		c.unshare()
+		last, o := size/chunkSize, int(size%chunkSize)
+		for i, b := range c.data {
+			if i > last || i == last && o == 0 {
+				delete(c.data, i)
+			} else if i == last && len(b) > o {
+				c.data[i] = b[:o]
+			}
+		}
+	}
+	c.size = size
+}
+
+func (c *chunks) unshare() {
+	if !c.shared {
+		return
+	}
+	data := make(map[int64][]byte, len(c.data))
+	for i, b := range c.data {
+		data[i] = b
+	}
+	c.data = data
+	c.owned = make(map[int64]bool)
+	c.shared = false
+}
+
+// seekData returns the offset of the first block containing data at or after
+// off.
+func (c *chunks) seekData(off int64) (int64, error) {
+	if off < 0 {
+		return 0, ErrInvalid
+	}
+	if off >= c.size {
+		return 0, ErrNoData
+	}
+	i := off / chunkSize
+	if _, ok := c.data[i]; ok {
+		return off, nil
+	}
+	next := int64(-1)
+	for j := range c.data {
+		if j > i && (next < 0 || j < next) {
+			next = j
+		}
+	}
+	if next < 0 || next*chunkSize >= c.size {
+		return 0, ErrNoData
+	}
+	return next * chunkSize, nil
+}
+
+// seekHole returns the offset of the first hole at or after off, with the end
+// of the contents counting as a hole.
+func (c *chunks) seekHole(off int64) (int64, error) {
+	if off < 0 {
+		return 0, ErrInvalid
+	}
+	if off >= c.size {
+		return 0, ErrNoData
+	}
+	i := off / chunkSize
+	for {
+		if _, ok := c.data[i]; !ok {
+			break
+		}
+		i++
+	}
+	hole := i * chunkSize
+	if hole < off {
+		hole = off
+	}
+	if hole > c.size {
+		hole = c.size
+	}
+	return hole, nil
+}
@@ -0,0 +1,189 @@
+package os
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func readChunks(c *chunks) []byte {
+	p := make([]byte, c.size)
+	c.readAt(p, 0)
+	return p
+}
+
+func TestChunksSeek(t *testing.T) {
+	// blocks 0 and 2 contain data, block 1 is a hole, and the contents end
+	// part way through the hole of block 3
+	var c chunks
+	c.writeAt([]byte("a"), 0)
+	c.writeAt([]byte("b"), 2*chunkSize)
+	c.truncate(3*chunkSize + 100)
+	for n, test := range [...]struct {
+		hole   bool
+		offset int64
+		result int64
+		err    error
+	}{
+		{false, 0, 0, nil},
+		{false, 10, 10, nil},
+		{false, chunkSize, 2 * chunkSize, nil},
+		{false, 2*chunkSize + 5, 2*chunkSize + 5, nil},
+		{false, 3 * chunkSize, 0, ErrNoData},
+		{false, 3*chunkSize + 100, 0, ErrNoData},
+		{false, 4 * chunkSize, 0, ErrNoData},
+		{false, -1, 0, ErrInvalid},
+		{true, 0, chunkSize, nil},
+		{true, chunkSize + 1, chunkSize + 1, nil},
+		{true, 2 * chunkSize, 3 * chunkSize, nil},
+		{true, 3*chunkSize + 99, 3*chunkSize + 99, nil},
+		{true, 3*chunkSize + 100, 0, ErrNoData},
+		{true, -1, 0, ErrInvalid},
+	} {
+		seek := c.seekData
+		if test.hole {
+			seek = c.seekHole
+		}
+		if result, err := seek(test.offset); err != test.err {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.err, err)
+		} else if result != test.result {
+			t.Errorf("test %d: expecting offset %d, got %d", n+1, test.result, result)
+		}
+	}
+	var full chunks
+	full.writeAt(make([]byte, chunkSize+10), 0)
+	if hole, err := full.seekHole(5); err != nil || hole != chunkSize+10 {
+		t.Errorf("expecting hole at end of contents (%d), got %d, %v", chunkSize+10, hole, err)
+	}
+}
+
+func TestChunksTruncate(t *testing.T) {
+	for n, test := range [...]struct {
+		write, shrink, grow, allocated int64
+	}{
+		{10, 5, 20, chunkSize},
+		{chunkSize, 10, 2 * chunkSize, chunkSize},
+		{2*chunkSize + 10, chunkSize, 3 * chunkSize, chunkSize},
+		{2*chunkSize + 10, chunkSize + 1, 3 * chunkSize, 2 * chunkSize},
+		{chunkSize, 0, chunkSize, 0},
+	} {
+		var c chunks
+		c.writeAt(bytes.Repeat([]byte{'a'}, int(test.write)), 0)
+		c.truncate(test.shrink)
+		c.truncate(test.grow)
+		expected := make([]byte, test.grow)
+		copy(expected, bytes.Repeat([]byte{'a'}, int(test.shrink)))
+		if got := readChunks(&c); !bytes.Equal(got, expected) {
+			t.Errorf("test %d: contents past the shrunk size are not zero", n+1)
+		}
+		if a := c.allocated(); a != test.allocated {
+			t.Errorf("test %d: expecting %d bytes allocated, got %d", n+1, test.allocated, a)
+		}
+	}
+}
+
+func TestChunksCopyOnWrite(t *testing.T) {
+	for n, test := range [...]struct {
+		owned, shared bool
+	}{
+		{true, false},
+		{false, false},
+		{true, true},
+		{false, true},
+	} {
+		orig := bytes.Repeat([]byte{'a'}, chunkSize+10)
+		c := newChunks(orig, test.owned)
+		var other chunks
+		if test.shared {
+			c.shared = true
+			other = c
+		}
+		c.writeAt([]byte("bb"), chunkSize-1)
+		c.writeAt([]byte("c"), 0)
+		c.truncate(chunkSize + 5)
+		expected := bytes.Repeat([]byte{'a'}, chunkSize+5)
+		copy(expected, "c")
+		copy(expected[chunkSize-1:], "bb")
+		if !bytes.Equal(readChunks(&c), expected) {
+			t.Errorf("test %d: contents not written", n+1)
+		}
+		if inPlace := orig[0] == 'c'; inPlace != (test.owned && !test.shared) {
+			t.Errorf("test %d: expecting write in place %v, got %v", n+1, test.owned && !test.shared, inPlace)
+		}
+		if test.shared {
+			if !bytes.Equal(readChunks(&other), bytes.Repeat([]byte{'a'}, chunkSize+10)) {
+				t.Errorf("test %d: shared contents changed", n+1)
+			}
+			other.writeAt([]byte("d"), 1)
+			if got := readChunks(&c); got[1] != 'a' {
+				t.Errorf("test %d: write to shared contents changed the original", n+1)
+			}
+		}
+		c.writeAt([]byte("e"), 1)
+		if b := c.data[0]; b[1] != 'e' {
+			t.Errorf("test %d: second write to a copied block was lost", n+1)
+		}
+	}
+}
+
+const benchmarkFileSize = 64 << 20
+
+func BenchmarkAppend(b *testing.B) {
+	buf := make([]byte, 32<<10)
+	b.SetBytes(int64(len(buf)))
+	f := New()
+	fl, err := f.OpenFile("/file", O_WRONLY|O_CREATE|O_APPEND, 0600)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer fl.Close()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := fl.Write(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRandomWrite(b *testing.B) {
+	buf := make([]byte, 512)
+	b.SetBytes(int64(len(buf)))
+	f := New()
+	fl, err := f.Create("/file")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer fl.Close()
+	if err := fl.Truncate(benchmarkFileSize); err != nil {
+		b.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(0))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := fl.WriteAt(buf, r.Int63n(benchmarkFileSize-int64(len(buf)))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRandomWriteSnapshot(b *testing.B) {
+	buf := make([]byte, 512)
+	b.SetBytes(int64(len(buf)))
+	f := New()
+	f.WriteBytes("/file", 0600, make([]byte, benchmarkFileSize))
+	fl, err := f.OpenFile("/file", O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer fl.Close()
+	r := rand.New(rand.NewSource(0))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if n%64 == 0 {
+			f.Snapshot()
+		}
+		if _, err := fl.WriteAt(buf, r.Int63n(benchmarkFileSize-int64(len(buf)))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
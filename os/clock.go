@@ -0,0 +1,62 @@
+package os
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a source of the current time, used for the timestamps of a
+// filesystem.
+type Clock interface {
+	Now() time.Time
+}
+
+func clockNow(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// SetClock sets the clock used for the timestamps of the filesystem. A nil
+// clock uses the system time.
+func (f *FS) SetClock(c Clock) {
+	f.optMu.Lock()
+	defer f.optMu.Unlock()
+	f.clock = c
+}
+
+// ManualClock is a Clock whose time only changes when it is set or advanced.
+//
+// It is safe for concurrent use.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock creates a ManualClock set to the given time.
+func NewManualClock(t time.Time) *ManualClock {
+	return &ManualClock{now: t}
+}
+
+func (m *ManualClock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// Set sets the time of the clock.
+func (m *ManualClock) Set(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = t
+}
+
+// Advance moves the time of the clock forward by the given duration, returning
+// the new time.
+func (m *ManualClock) Advance(d time.Duration) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+	return m.now
+}
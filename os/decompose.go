@@ -0,0 +1,649 @@
+// Code generated by gen_decompose.go from Unicode 15.0.0 UnicodeData.txt; DO NOT EDIT.
+
+package os
+
+// unicodeVersion is the version of Unicode from which the tables are
+// generated.
+const unicodeVersion = "15.0.0"
+
+// decompositions maps characters to their full canonical decompositions. The
+// algorithmic decompositions of Hangul syllables are not included.
+var decompositions = map[rune]string{
+	0x00c0: "A\u0300", 0x00c1: "A\u0301", 0x00c2: "A\u0302", 0x00c3: "A\u0303",
+	0x00c4: "A\u0308", 0x00c5: "A\u030a", 0x00c7: "C\u0327", 0x00c8: "E\u0300",
+	0x00c9: "E\u0301", 0x00ca: "E\u0302", 0x00cb: "E\u0308", 0x00cc: "I\u0300",
+	0x00cd: "I\u0301", 0x00ce: "I\u0302", 0x00cf: "I\u0308", 0x00d1: "N\u0303",
+	0x00d2: "O\u0300", 0x00d3: "O\u0301", 0x00d4: "O\u0302", 0x00d5: "O\u0303",
+	0x00d6: "O\u0308", 0x00d9: "U\u0300", 0x00da: "U\u0301", 0x00db: "U\u0302",
+	0x00dc: "U\u0308", 0x00dd: "Y\u0301", 0x00e0: "a\u0300", 0x00e1: "a\u0301",
+	0x00e2: "a\u0302", 0x00e3: "a\u0303", 0x00e4: "a\u0308", 0x00e5: "a\u030a",
+	0x00e7: "c\u0327", 0x00e8: "e\u0300", 0x00e9: "e\u0301", 0x00ea: "e\u0302",
+	0x00eb: "e\u0308", 0x00ec: "i\u0300", 0x00ed: "i\u0301", 0x00ee: "i\u0302",
+	0x00ef: "i\u0308", 0x00f1: "n\u0303", 0x00f2: "o\u0300", 0x00f3: "o\u0301",
+	0x00f4: "o\u0302", 0x00f5: "o\u0303", 0x00f6: "o\u0308", 0x00f9: "u\u0300",
+	0x00fa: "u\u0301", 0x00fb: "u\u0302", 0x00fc: "u\u0308", 0x00fd: "y\u0301",
+	0x00ff: "y\u0308", 0x0100: "A\u0304", 0x0101: "a\u0304", 0x0102: "A\u0306",
+	0x0103: "a\u0306", 0x0104: "A\u0328", 0x0105: "a\u0328", 0x0106: "C\u0301",
+	0x0107: "c\u0301", 0x0108: "C\u0302", 0x0109: "c\u0302", 0x010a: "C\u0307",
+	0x010b: "c\u0307", 0x010c: "C\u030c", 0x010d: "c\u030c", 0x010e: "D\u030c",
+	0x010f: "d\u030c", 0x0112: "E\u0304", 0x0113: "e\u0304", 0x0114: "E\u0306",
+	0x0115: "e\u0306", 0x0116: "E\u0307", 0x0117: "e\u0307", 0x0118: "E\u0328",
+	0x0119: "e\u0328", 0x011a: "E\u030c", 0x011b: "e\u030c", 0x011c: "G\u0302",
+	0x011d: "g\u0302", 0x011e: "G\u0306", 0x011f: "g\u0306", 0x0120: "G\u0307",
+	0x0121: "g\u0307", 0x0122: "G\u0327", 0x0123: "g\u0327", 0x0124: "H\u0302",
+	0x0125: "h\u0302", 0x0128: "I\u0303", 0x0129: "i\u0303", 0x012a: "I\u0304",
+	0x012b: "i\u0304", 0x012c: "I\u0306", 0x012d: "i\u0306", 0x012e: "I\u0328",
+	0x012f: "i\u0328", 0x0130: "I\u0307", 0x0134: "J\u0302", 0x0135: "j\u0302",
+	0x0136: "K\u0327", 0x0137: "k\u0327", 0x0139: "L\u0301", 0x013a: "l\u0301",
+	0x013b: "L\u0327", 0x013c: "l\u0327", 0x013d: "L\u030c", 0x013e: "l\u030c",
+	0x0143: "N\u0301", 0x0144: "n\u0301", 0x0145: "N\u0327", 0x0146: "n\u0327",
+	0x0147: "N\u030c", 0x0148: "n\u030c", 0x014c: "O\u0304", 0x014d: "o\u0304",
+	0x014e: "O\u0306", 0x014f: "o\u0306", 0x0150: "O\u030b", 0x0151: "o\u030b",
+	0x0154: "R\u0301", 0x0155: "r\u0301", 0x0156: "R\u0327", 0x0157: "r\u0327",
+	0x0158: "R\u030c", 0x0159: "r\u030c", 0x015a: "S\u0301", 0x015b: "s\u0301",
+	0x015c: "S\u0302", 0x015d: "s\u0302", 0x015e: "S\u0327", 0x015f: "s\u0327",
+	0x0160: "S\u030c", 0x0161: "s\u030c", 0x0162: "T\u0327", 0x0163: "t\u0327",
+	0x0164: "T\u030c", 0x0165: "t\u030c", 0x0168: "U\u0303", 0x0169: "u\u0303",
+	0x016a: "U\u0304", 0x016b: "u\u0304", 0x016c: "U\u0306", 0x016d: "u\u0306",
+	0x016e: "U\u030a", 0x016f: "u\u030a", 0x0170: "U\u030b", 0x0171: "u\u030b",
+	0x0172: "U\u0328", 0x0173: "u\u0328", 0x0174: "W\u0302", 0x0175: "w\u0302",
+	0x0176: "Y\u0302", 0x0177: "y\u0302", 0x0178: "Y\u0308", 0x0179: "Z\u0301",
+	0x017a: "z\u0301", 0x017b: "Z\u0307", 0x017c: "z\u0307", 0x017d: "Z\u030c",
+	0x017e: "z\u030c", 0x01a0: "O\u031b", 0x01a1: "o\u031b", 0x01af: "U\u031b",
+	0x01b0: "u\u031b", 0x01cd: "A\u030c", 0x01ce: "a\u030c", 0x01cf: "I\u030c",
+	0x01d0: "i\u030c", 0x01d1: "O\u030c", 0x01d2: "o\u030c", 0x01d3: "U\u030c",
+	0x01d4: "u\u030c", 0x01d5: "U\u0308\u0304", 0x01d6: "u\u0308\u0304", 0x01d7: "U\u0308\u0301",
+	0x01d8: "u\u0308\u0301", 0x01d9: "U\u0308\u030c", 0x01da: "u\u0308\u030c", 0x01db: "U\u0308\u0300",
+	0x01dc: "u\u0308\u0300", 0x01de: "A\u0308\u0304", 0x01df: "a\u0308\u0304", 0x01e0: "A\u0307\u0304",
+	0x01e1: "a\u0307\u0304", 0x01e2: "\u00c6\u0304", 0x01e3: "\u00e6\u0304", 0x01e6: "G\u030c",
+	0x01e7: "g\u030c", 0x01e8: "K\u030c", 0x01e9: "k\u030c", 0x01ea: "O\u0328",
+	0x01eb: "o\u0328", 0x01ec: "O\u0328\u0304", 0x01ed: "o\u0328\u0304", 0x01ee: "\u01b7\u030c",
+	0x01ef: "\u0292\u030c", 0x01f0: "j\u030c", 0x01f4: "G\u0301", 0x01f5: "g\u0301",
+	0x01f8: "N\u0300", 0x01f9: "n\u0300", 0x01fa: "A\u030a\u0301", 0x01fb: "a\u030a\u0301",
+	0x01fc: "\u00c6\u0301", 0x01fd: "\u00e6\u0301", 0x01fe: "\u00d8\u0301", 0x01ff: "\u00f8\u0301",
+	0x0200: "A\u030f", 0x0201: "a\u030f", 0x0202: "A\u0311", 0x0203: "a\u0311",
+	0x0204: "E\u030f", 0x0205: "e\u030f", 0x0206: "E\u0311", 0x0207: "e\u0311",
+	0x0208: "I\u030f", 0x0209: "i\u030f", 0x020a: "I\u0311", 0x020b: "i\u0311",
+	0x020c: "O\u030f", 0x020d: "o\u030f", 0x020e: "O\u0311", 0x020f: "o\u0311",
+	0x0210: "R\u030f", 0x0211: "r\u030f", 0x0212: "R\u0311", 0x0213: "r\u0311",
+	0x0214: "U\u030f", 0x0215: "u\u030f", 0x0216: "U\u0311", 0x0217: "u\u0311",
+	0x0218: "S\u0326", 0x0219: "s\u0326", 0x021a: "T\u0326", 0x021b: "t\u0326",
+	0x021e: "H\u030c", 0x021f: "h\u030c", 0x0226: "A\u0307", 0x0227: "a\u0307",
+	0x0228: "E\u0327", 0x0229: "e\u0327", 0x022a: "O\u0308\u0304", 0x022b: "o\u0308\u0304",
+	0x022c: "O\u0303\u0304", 0x022d: "o\u0303\u0304", 0x022e: "O\u0307", 0x022f: "o\u0307",
+	0x0230: "O\u0307\u0304", 0x0231: "o\u0307\u0304", 0x0232: "Y\u0304", 0x0233: "y\u0304",
+	0x0340: "\u0300", 0x0341: "\u0301", 0x0343: "\u0313", 0x0344: "\u0308\u0301",
+	0x0374: "\u02b9", 0x037e: ";", 0x0385: "\u00a8\u0301", 0x0386: "\u0391\u0301",
+	0x0387: "\u00b7", 0x0388: "\u0395\u0301", 0x0389: "\u0397\u0301", 0x038a: "\u0399\u0301",
+	0x038c: "\u039f\u0301", 0x038e: "\u03a5\u0301", 0x038f: "\u03a9\u0301", 0x0390: "\u03b9\u0308\u0301",
+	0x03aa: "\u0399\u0308", 0x03ab: "\u03a5\u0308", 0x03ac: "\u03b1\u0301", 0x03ad: "\u03b5\u0301",
+	0x03ae: "\u03b7\u0301", 0x03af: "\u03b9\u0301", 0x03b0: "\u03c5\u0308\u0301", 0x03ca: "\u03b9\u0308",
+	0x03cb: "\u03c5\u0308", 0x03cc: "\u03bf\u0301", 0x03cd: "\u03c5\u0301", 0x03ce: "\u03c9\u0301",
+	0x03d3: "\u03d2\u0301", 0x03d4: "\u03d2\u0308", 0x0400: "\u0415\u0300", 0x0401: "\u0415\u0308",
+	0x0403: "\u0413\u0301", 0x0407: "\u0406\u0308", 0x040c: "\u041a\u0301", 0x040d: "\u0418\u0300",
+	0x040e: "\u0423\u0306", 0x0419: "\u0418\u0306", 0x0439: "\u0438\u0306", 0x0450: "\u0435\u0300",
+	0x0451: "\u0435\u0308", 0x0453: "\u0433\u0301", 0x0457: "\u0456\u0308", 0x045c: "\u043a\u0301",
+	0x045d: "\u0438\u0300", 0x045e: "\u0443\u0306", 0x0476: "\u0474\u030f", 0x0477: "\u0475\u030f",
+	0x04c1: "\u0416\u0306", 0x04c2: "\u0436\u0306", 0x04d0: "\u0410\u0306", 0x04d1: "\u0430\u0306",
+	0x04d2: "\u0410\u0308", 0x04d3: "\u0430\u0308", 0x04d6: "\u0415\u0306", 0x04d7: "\u0435\u0306",
+	0x04da: "\u04d8\u0308", 0x04db: "\u04d9\u0308", 0x04dc: "\u0416\u0308", 0x04dd: "\u0436\u0308",
+	0x04de: "\u0417\u0308", 0x04df: "\u0437\u0308", 0x04e2: "\u0418\u0304", 0x04e3: "\u0438\u0304",
+	0x04e4: "\u0418\u0308", 0x04e5: "\u0438\u0308", 0x04e6: "\u041e\u0308", 0x04e7: "\u043e\u0308",
+	0x04ea: "\u04e8\u0308", 0x04eb: "\u04e9\u0308", 0x04ec: "\u042d\u0308", 0x04ed: "\u044d\u0308",
+	0x04ee: "\u0423\u0304", 0x04ef: "\u0443\u0304", 0x04f0: "\u0423\u0308", 0x04f1: "\u0443\u0308",
+	0x04f2: "\u0423\u030b", 0x04f3: "\u0443\u030b", 0x04f4: "\u0427\u0308", 0x04f5: "\u0447\u0308",
+	0x04f8: "\u042b\u0308", 0x04f9: "\u044b\u0308", 0x0622: "\u0627\u0653", 0x0623: "\u0627\u0654",
+	0x0624: "\u0648\u0654", 0x0625: "\u0627\u0655", 0x0626: "\u064a\u0654", 0x06c0: "\u06d5\u0654",
+	0x06c2: "\u06c1\u0654", 0x06d3: "\u06d2\u0654", 0x0929: "\u0928\u093c", 0x0931: "\u0930\u093c",
+	0x0934: "\u0933\u093c", 0x0958: "\u0915\u093c", 0x0959: "\u0916\u093c", 0x095a: "\u0917\u093c",
+	0x095b: "\u091c\u093c", 0x095c: "\u0921\u093c", 0x095d: "\u0922\u093c", 0x095e: "\u092b\u093c",
+	0x095f: "\u092f\u093c", 0x09cb: "\u09c7\u09be", 0x09cc: "\u09c7\u09d7", 0x09dc: "\u09a1\u09bc",
+	0x09dd: "\u09a2\u09bc", 0x09df: "\u09af\u09bc", 0x0a33: "\u0a32\u0a3c", 0x0a36: "\u0a38\u0a3c",
+	0x0a59: "\u0a16\u0a3c", 0x0a5a: "\u0a17\u0a3c", 0x0a5b: "\u0a1c\u0a3c", 0x0a5e: "\u0a2b\u0a3c",
+	0x0b48: "\u0b47\u0b56", 0x0b4b: "\u0b47\u0b3e", 0x0b4c: "\u0b47\u0b57", 0x0b5c: "\u0b21\u0b3c",
+	0x0b5d: "\u0b22\u0b3c", 0x0b94: "\u0b92\u0bd7", 0x0bca: "\u0bc6\u0bbe", 0x0bcb: "\u0bc7\u0bbe",
+	0x0bcc: "\u0bc6\u0bd7", 0x0c48: "\u0c46\u0c56", 0x0cc0: "\u0cbf\u0cd5", 0x0cc7: "\u0cc6\u0cd5",
+	0x0cc8: "\u0cc6\u0cd6", 0x0cca: "\u0cc6\u0cc2", 0x0ccb: "\u0cc6\u0cc2\u0cd5", 0x0d4a: "\u0d46\u0d3e",
+	0x0d4b: "\u0d47\u0d3e", 0x0d4c: "\u0d46\u0d57", 0x0dda: "\u0dd9\u0dca", 0x0ddc: "\u0dd9\u0dcf",
+	0x0ddd: "\u0dd9\u0dcf\u0dca", 0x0dde: "\u0dd9\u0ddf", 0x0f43: "\u0f42\u0fb7", 0x0f4d: "\u0f4c\u0fb7",
+	0x0f52: "\u0f51\u0fb7", 0x0f57: "\u0f56\u0fb7", 0x0f5c: "\u0f5b\u0fb7", 0x0f69: "\u0f40\u0fb5",
+	0x0f73: "\u0f71\u0f72", 0x0f75: "\u0f71\u0f74", 0x0f76: "\u0fb2\u0f80", 0x0f78: "\u0fb3\u0f80",
+	0x0f81: "\u0f71\u0f80", 0x0f93: "\u0f92\u0fb7", 0x0f9d: "\u0f9c\u0fb7", 0x0fa2: "\u0fa1\u0fb7",
+	0x0fa7: "\u0fa6\u0fb7", 0x0fac: "\u0fab\u0fb7", 0x0fb9: "\u0f90\u0fb5", 0x1026: "\u1025\u102e",
+	0x1b06: "\u1b05\u1b35", 0x1b08: "\u1b07\u1b35", 0x1b0a: "\u1b09\u1b35", 0x1b0c: "\u1b0b\u1b35",
+	0x1b0e: "\u1b0d\u1b35", 0x1b12: "\u1b11\u1b35", 0x1b3b: "\u1b3a\u1b35", 0x1b3d: "\u1b3c\u1b35",
+	0x1b40: "\u1b3e\u1b35", 0x1b41: "\u1b3f\u1b35", 0x1b43: "\u1b42\u1b35", 0x1e00: "A\u0325",
+	0x1e01: "a\u0325", 0x1e02: "B\u0307", 0x1e03: "b\u0307", 0x1e04: "B\u0323",
+	0x1e05: "b\u0323", 0x1e06: "B\u0331", 0x1e07: "b\u0331", 0x1e08: "C\u0327\u0301",
+	0x1e09: "c\u0327\u0301", 0x1e0a: "D\u0307", 0x1e0b: "d\u0307", 0x1e0c: "D\u0323",
+	0x1e0d: "d\u0323", 0x1e0e: "D\u0331", 0x1e0f: "d\u0331", 0x1e10: "D\u0327",
+	0x1e11: "d\u0327", 0x1e12: "D\u032d", 0x1e13: "d\u032d", 0x1e14: "E\u0304\u0300",
+	0x1e15: "e\u0304\u0300", 0x1e16: "E\u0304\u0301", 0x1e17: "e\u0304\u0301", 0x1e18: "E\u032d",
+	0x1e19: "e\u032d", 0x1e1a: "E\u0330", 0x1e1b: "e\u0330", 0x1e1c: "E\u0327\u0306",
+	0x1e1d: "e\u0327\u0306", 0x1e1e: "F\u0307", 0x1e1f: "f\u0307", 0x1e20: "G\u0304",
+	0x1e21: "g\u0304", 0x1e22: "H\u0307", 0x1e23: "h\u0307", 0x1e24: "H\u0323",
+	0x1e25: "h\u0323", 0x1e26: "H\u0308", 0x1e27: "h\u0308", 0x1e28: "H\u0327",
+	0x1e29: "h\u0327", 0x1e2a: "H\u032e", 0x1e2b: "h\u032e", 0x1e2c: "I\u0330",
+	0x1e2d: "i\u0330", 0x1e2e: "I\u0308\u0301", 0x1e2f: "i\u0308\u0301", 0x1e30: "K\u0301",
+	0x1e31: "k\u0301", 0x1e32: "K\u0323", 0x1e33: "k\u0323", 0x1e34: "K\u0331",
+	0x1e35: "k\u0331", 0x1e36: "L\u0323", 0x1e37: "l\u0323", 0x1e38: "L\u0323\u0304",
+	0x1e39: "l\u0323\u0304", 0x1e3a: "L\u0331", 0x1e3b: "l\u0331", 0x1e3c: "L\u032d",
+	0x1e3d: "l\u032d", 0x1e3e: "M\u0301", 0x1e3f: "m\u0301", 0x1e40: "M\u0307",
+	0x1e41: "m\u0307", 0x1e42: "M\u0323", 0x1e43: "m\u0323", 0x1e44: "N\u0307",
+	0x1e45: "n\u0307", 0x1e46: "N\u0323", 0x1e47: "n\u0323", 0x1e48: "N\u0331",
+	0x1e49: "n\u0331", 0x1e4a: "N\u032d", 0x1e4b: "n\u032d", 0x1e4c: "O\u0303\u0301",
+	0x1e4d: "o\u0303\u0301", 0x1e4e: "O\u0303\u0308", 0x1e4f: "o\u0303\u0308", 0x1e50: "O\u0304\u0300",
+	0x1e51: "o\u0304\u0300", 0x1e52: "O\u0304\u0301", 0x1e53: "o\u0304\u0301", 0x1e54: "P\u0301",
+	0x1e55: "p\u0301", 0x1e56: "P\u0307", 0x1e57: "p\u0307", 0x1e58: "R\u0307",
+	0x1e59: "r\u0307", 0x1e5a: "R\u0323", 0x1e5b: "r\u0323", 0x1e5c: "R\u0323\u0304",
+	0x1e5d: "r\u0323\u0304", 0x1e5e: "R\u0331", 0x1e5f: "r\u0331", 0x1e60: "S\u0307",
+	0x1e61: "s\u0307", 0x1e62: "S\u0323", 0x1e63: "s\u0323", 0x1e64: "S\u0301\u0307",
+	0x1e65: "s\u0301\u0307", 0x1e66: "S\u030c\u0307", 0x1e67: "s\u030c\u0307", 0x1e68: "S\u0323\u0307",
+	0x1e69: "s\u0323\u0307", 0x1e6a: "T\u0307", 0x1e6b: "t\u0307", 0x1e6c: "T\u0323",
+	0x1e6d: "t\u0323", 0x1e6e: "T\u0331", 0x1e6f: "t\u0331", 0x1e70: "T\u032d",
+	0x1e71: "t\u032d", 0x1e72: "U\u0324", 0x1e73: "u\u0324", 0x1e74: "U\u0330",
+	0x1e75: "u\u0330", 0x1e76: "U\u032d", 0x1e77: "u\u032d", 0x1e78: "U\u0303\u0301",
+	0x1e79: "u\u0303\u0301", 0x1e7a: "U\u0304\u0308", 0x1e7b: "u\u0304\u0308", 0x1e7c: "V\u0303",
+	0x1e7d: "v\u0303", 0x1e7e: "V\u0323", 0x1e7f: "v\u0323", 0x1e80: "W\u0300",
+	0x1e81: "w\u0300", 0x1e82: "W\u0301", 0x1e83: "w\u0301", 0x1e84: "W\u0308",
+	0x1e85: "w\u0308", 0x1e86: "W\u0307", 0x1e87: "w\u0307", 0x1e88: "W\u0323",
+	0x1e89: "w\u0323", 0x1e8a: "X\u0307", 0x1e8b: "x\u0307", 0x1e8c: "X\u0308",
+	0x1e8d: "x\u0308", 0x1e8e: "Y\u0307", 0x1e8f: "y\u0307", 0x1e90: "Z\u0302",
+	0x1e91: "z\u0302", 0x1e92: "Z\u0323", 0x1e93: "z\u0323", 0x1e94: "Z\u0331",
+	0x1e95: "z\u0331", 0x1e96: "h\u0331", 0x1e97: "t\u0308", 0x1e98: "w\u030a",
+	0x1e99: "y\u030a", 0x1e9b: "\u017f\u0307", 0x1ea0: "A\u0323", 0x1ea1: "a\u0323",
+	0x1ea2: "A\u0309", 0x1ea3: "a\u0309", 0x1ea4: "A\u0302\u0301", 0x1ea5: "a\u0302\u0301",
+	0x1ea6: "A\u0302\u0300", 0x1ea7: "a\u0302\u0300", 0x1ea8: "A\u0302\u0309", 0x1ea9: "a\u0302\u0309",
+	0x1eaa: "A\u0302\u0303", 0x1eab: "a\u0302\u0303", 0x1eac: "A\u0323\u0302", 0x1ead: "a\u0323\u0302",
+	0x1eae: "A\u0306\u0301", 0x1eaf: "a\u0306\u0301", 0x1eb0: "A\u0306\u0300", 0x1eb1: "a\u0306\u0300",
+	0x1eb2: "A\u0306\u0309", 0x1eb3: "a\u0306\u0309", 0x1eb4: "A\u0306\u0303", 0x1eb5: "a\u0306\u0303",
+	0x1eb6: "A\u0323\u0306", 0x1eb7: "a\u0323\u0306", 0x1eb8: "E\u0323", 0x1eb9: "e\u0323",
+	0x1eba: "E\u0309", 0x1ebb: "e\u0309", 0x1ebc: "E\u0303", 0x1ebd: "e\u0303",
+	0x1ebe: "E\u0302\u0301", 0x1ebf: "e\u0302\u0301", 0x1ec0: "E\u0302\u0300", 0x1ec1: "e\u0302\u0300",
+	0x1ec2: "E\u0302\u0309", 0x1ec3: "e\u0302\u0309", 0x1ec4: "E\u0302\u0303", 0x1ec5: "e\u0302\u0303",
+	0x1ec6: "E\u0323\u0302", 0x1ec7: "e\u0323\u0302", 0x1ec8: "I\u0309", 0x1ec9: "i\u0309",
+	0x1eca: "I\u0323", 0x1ecb: "i\u0323", 0x1ecc: "O\u0323", 0x1ecd: "o\u0323",
+	0x1ece: "O\u0309", 0x1ecf: "o\u0309", 0x1ed0: "O\u0302\u0301", 0x1ed1: "o\u0302\u0301",
+	0x1ed2: "O\u0302\u0300", 0x1ed3: "o\u0302\u0300", 0x1ed4: "O\u0302\u0309", 0x1ed5: "o\u0302\u0309",
+	0x1ed6: "O\u0302\u0303", 0x1ed7: "o\u0302\u0303", 0x1ed8: "O\u0323\u0302", 0x1ed9: "o\u0323\u0302",
+	0x1eda: "O\u031b\u0301", 0x1edb: "o\u031b\u0301", 0x1edc: "O\u031b\u0300", 0x1edd: "o\u031b\u0300",
+	0x1ede: "O\u031b\u0309", 0x1edf: "o\u031b\u0309", 0x1ee0: "O\u031b\u0303", 0x1ee1: "o\u031b\u0303",
+	0x1ee2: "O\u031b\u0323", 0x1ee3: "o\u031b\u0323", 0x1ee4: "U\u0323", 0x1ee5: "u\u0323",
+	0x1ee6: "U\u0309", 0x1ee7: "u\u0309", 0x1ee8: "U\u031b\u0301", 0x1ee9: "u\u031b\u0301",
+	0x1eea: "U\u031b\u0300", 0x1eeb: "u\u031b\u0300", 0x1eec: "U\u031b\u0309", 0x1eed: "u\u031b\u0309",
+	0x1eee: "U\u031b\u0303", 0x1eef: "u\u031b\u0303", 0x1ef0: "U\u031b\u0323", 0x1ef1: "u\u031b\u0323",
+	0x1ef2: "Y\u0300", 0x1ef3: "y\u0300", 0x1ef4: "Y\u0323", 0x1ef5: "y\u0323",
+	0x1ef6: "Y\u0309", 0x1ef7: "y\u0309", 0x1ef8: "Y\u0303", 0x1ef9: "y\u0303",
+	0x1f00: "\u03b1\u0313", 0x1f01: "\u03b1\u0314", 0x1f02: "\u03b1\u0313\u0300", 0x1f03: "\u03b1\u0314\u0300",
+	0x1f04: "\u03b1\u0313\u0301", 0x1f05: "\u03b1\u0314\u0301", 0x1f06: "\u03b1\u0313\u0342", 0x1f07: "\u03b1\u0314\u0342",
+	0x1f08: "\u0391\u0313", 0x1f09: "\u0391\u0314", 0x1f0a: "\u0391\u0313\u0300", 0x1f0b: "\u0391\u0314\u0300",
+	0x1f0c: "\u0391\u0313\u0301", 0x1f0d: "\u0391\u0314\u0301", 0x1f0e: "\u0391\u0313\u0342", 0x1f0f: "\u0391\u0314\u0342",
+	0x1f10: "\u03b5\u0313", 0x1f11: "\u03b5\u0314", 0x1f12: "\u03b5\u0313\u0300", 0x1f13: "\u03b5\u0314\u0300",
+	0x1f14: "\u03b5\u0313\u0301", 0x1f15: "\u03b5\u0314\u0301", 0x1f18: "\u0395\u0313", 0x1f19: "\u0395\u0314",
+	0x1f1a: "\u0395\u0313\u0300", 0x1f1b: "\u0395\u0314\u0300", 0x1f1c: "\u0395\u0313\u0301", 0x1f1d: "\u0395\u0314\u0301",
+	0x1f20: "\u03b7\u0313", 0x1f21: "\u03b7\u0314", 0x1f22: "\u03b7\u0313\u0300", 0x1f23: "\u03b7\u0314\u0300",
+	0x1f24: "\u03b7\u0313\u0301", 0x1f25: "\u03b7\u0314\u0301", 0x1f26: "\u03b7\u0313\u0342", 0x1f27: "\u03b7\u0314\u0342",
+	0x1f28: "\u0397\u0313", 0x1f29: "\u0397\u0314", 0x1f2a: "\u0397\u0313\u0300", 0x1f2b: "\u0397\u0314\u0300",
+	0x1f2c: "\u0397\u0313\u0301", 0x1f2d: "\u0397\u0314\u0301", 0x1f2e: "\u0397\u0313\u0342", 0x1f2f: "\u0397\u0314\u0342",
+	0x1f30: "\u03b9\u0313", 0x1f31: "\u03b9\u0314", 0x1f32: "\u03b9\u0313\u0300", 0x1f33: "\u03b9\u0314\u0300",
+	0x1f34: "\u03b9\u0313\u0301", 0x1f35: "\u03b9\u0314\u0301", 0x1f36: "\u03b9\u0313\u0342", 0x1f37: "\u03b9\u0314\u0342",
+	0x1f38: "\u0399\u0313", 0x1f39: "\u0399\u0314", 0x1f3a: "\u0399\u0313\u0300", 0x1f3b: "\u0399\u0314\u0300",
+	0x1f3c: "\u0399\u0313\u0301", 0x1f3d: "\u0399\u0314\u0301", 0x1f3e: "\u0399\u0313\u0342", 0x1f3f: "\u0399\u0314\u0342",
+	0x1f40: "\u03bf\u0313", 0x1f41: "\u03bf\u0314", 0x1f42: "\u03bf\u0313\u0300", 0x1f43: "\u03bf\u0314\u0300",
+	0x1f44: "\u03bf\u0313\u0301", 0x1f45: "\u03bf\u0314\u0301", 0x1f48: "\u039f\u0313", 0x1f49: "\u039f\u0314",
+	0x1f4a: "\u039f\u0313\u0300", 0x1f4b: "\u039f\u0314\u0300", 0x1f4c: "\u039f\u0313\u0301", 0x1f4d: "\u039f\u0314\u0301",
+	0x1f50: "\u03c5\u0313", 0x1f51: "\u03c5\u0314", 0x1f52: "\u03c5\u0313\u0300", 0x1f53: "\u03c5\u0314\u0300",
+	0x1f54: "\u03c5\u0313\u0301", 0x1f55: "\u03c5\u0314\u0301", 0x1f56: "\u03c5\u0313\u0342", 0x1f57: "\u03c5\u0314\u0342",
+	0x1f59: "\u03a5\u0314", 0x1f5b: "\u03a5\u0314\u0300", 0x1f5d: "\u03a5\u0314\u0301", 0x1f5f: "\u03a5\u0314\u0342",
+	0x1f60: "\u03c9\u0313", 0x1f61: "\u03c9\u0314", 0x1f62: "\u03c9\u0313\u0300", 0x1f63: "\u03c9\u0314\u0300",
+	0x1f64: "\u03c9\u0313\u0301", 0x1f65: "\u03c9\u0314\u0301", 0x1f66: "\u03c9\u0313\u0342", 0x1f67: "\u03c9\u0314\u0342",
+	0x1f68: "\u03a9\u0313", 0x1f69: "\u03a9\u0314", 0x1f6a: "\u03a9\u0313\u0300", 0x1f6b: "\u03a9\u0314\u0300",
+	0x1f6c: "\u03a9\u0313\u0301", 0x1f6d: "\u03a9\u0314\u0301", 0x1f6e: "\u03a9\u0313\u0342", 0x1f6f: "\u03a9\u0314\u0342",
+	0x1f70: "\u03b1\u0300", 0x1f71: "\u03b1\u0301", 0x1f72: "\u03b5\u0300", 0x1f73: "\u03b5\u0301",
+	0x1f74: "\u03b7\u0300", 0x1f75: "\u03b7\u0301", 0x1f76: "\u03b9\u0300", 0x1f77: "\u03b9\u0301",
+	0x1f78: "\u03bf\u0300", 0x1f79: "\u03bf\u0301", 0x1f7a: "\u03c5\u0300", 0x1f7b: "\u03c5\u0301",
+	0x1f7c: "\u03c9\u0300", 0x1f7d: "\u03c9\u0301", 0x1f80: "\u03b1\u0313\u0345", 0x1f81: "\u03b1\u0314\u0345",
+	0x1f82: "\u03b1\u0313\u0300\u0345", 0x1f83: "\u03b1\u0314\u0300\u0345", 0x1f84: "\u03b1\u0313\u0301\u0345", 0x1f85: "\u03b1\u0314\u0301\u0345",
+	0x1f86: "\u03b1\u0313\u0342\u0345", 0x1f87: "\u03b1\u0314\u0342\u0345", 0x1f88: "\u0391\u0313\u0345", 0x1f89: "\u0391\u0314\u0345",
+	0x1f8a: "\u0391\u0313\u0300\u0345", 0x1f8b: "\u0391\u0314\u0300\u0345", 0x1f8c: "\u0391\u0313\u0301\u0345", 0x1f8d: "\u0391\u0314\u0301\u0345",
+	0x1f8e: "\u0391\u0313\u0342\u0345", 0x1f8f: "\u0391\u0314\u0342\u0345", 0x1f90: "\u03b7\u0313\u0345", 0x1f91: "\u03b7\u0314\u0345",
+	0x1f92: "\u03b7\u0313\u0300\u0345", 0x1f93: "\u03b7\u0314\u0300\u0345", 0x1f94: "\u03b7\u0313\u0301\u0345", 0x1f95: "\u03b7\u0314\u0301\u0345",
+	0x1f96: "\u03b7\u0313\u0342\u0345", 0x1f97: "\u03b7\u0314\u0342\u0345", 0x1f98: "\u0397\u0313\u0345", 0x1f99: "\u0397\u0314\u0345",
+	0x1f9a: "\u0397\u0313\u0300\u0345", 0x1f9b: "\u0397\u0314\u0300\u0345", 0x1f9c: "\u0397\u0313\u0301\u0345", 0x1f9d: "\u0397\u0314\u0301\u0345",
+	0x1f9e: "\u0397\u0313\u0342\u0345", 0x1f9f: "\u0397\u0314\u0342\u0345", 0x1fa0: "\u03c9\u0313\u0345", 0x1fa1: "\u03c9\u0314\u0345",
+	0x1fa2: "\u03c9\u0313\u0300\u0345", 0x1fa3: "\u03c9\u0314\u0300\u0345", 0x1fa4: "\u03c9\u0313\u0301\u0345", 0x1fa5: "\u03c9\u0314\u0301\u0345",
+	0x1fa6: "\u03c9\u0313\u0342\u0345", 0x1fa7: "\u03c9\u0314\u0342\u0345", 0x1fa8: "\u03a9\u0313\u0345", 0x1fa9: "\u03a9\u0314\u0345",
+	0x1faa: "\u03a9\u0313\u0300\u0345", 0x1fab: "\u03a9\u0314\u0300\u0345", 0x1fac: "\u03a9\u0313\u0301\u0345", 0x1fad: "\u03a9\u0314\u0301\u0345",
+	0x1fae: "\u03a9\u0313\u0342\u0345", 0x1faf: "\u03a9\u0314\u0342\u0345", 0x1fb0: "\u03b1\u0306", 0x1fb1: "\u03b1\u0304",
+	0x1fb2: "\u03b1\u0300\u0345", 0x1fb3: "\u03b1\u0345", 0x1fb4: "\u03b1\u0301\u0345", 0x1fb6: "\u03b1\u0342",
+	0x1fb7: "\u03b1\u0342\u0345", 0x1fb8: "\u0391\u0306", 0x1fb9: "\u0391\u0304", 0x1fba: "\u0391\u0300",
+	0x1fbb: "\u0391\u0301", 0x1fbc: "\u0391\u0345", 0x1fbe: "\u03b9", 0x1fc1: "\u00a8\u0342",
+	0x1fc2: "\u03b7\u0300\u0345", 0x1fc3: "\u03b7\u0345", 0x1fc4: "\u03b7\u0301\u0345", 0x1fc6: "\u03b7\u0342",
+	0x1fc7: "\u03b7\u0342\u0345", 0x1fc8: "\u0395\u0300", 0x1fc9: "\u0395\u0301", 0x1fca: "\u0397\u0300",
+	0x1fcb: "\u0397\u0301", 0x1fcc: "\u0397\u0345", 0x1fcd: "\u1fbf\u0300", 0x1fce: "\u1fbf\u0301",
+	0x1fcf: "\u1fbf\u0342", 0x1fd0: "\u03b9\u0306", 0x1fd1: "\u03b9\u0304", 0x1fd2: "\u03b9\u0308\u0300",
+	0x1fd3: "\u03b9\u0308\u0301", 0x1fd6: "\u03b9\u0342", 0x1fd7: "\u03b9\u0308\u0342", 0x1fd8: "\u0399\u0306",
+	0x1fd9: "\u0399\u0304", 0x1fda: "\u0399\u0300", 0x1fdb: "\u0399\u0301", 0x1fdd: "\u1ffe\u0300",
+	0x1fde: "\u1ffe\u0301", 0x1fdf: "\u1ffe\u0342", 0x1fe0: "\u03c5\u0306", 0x1fe1: "\u03c5\u0304",
+	0x1fe2: "\u03c5\u0308\u0300", 0x1fe3: "\u03c5\u0308\u0301", 0x1fe4: "\u03c1\u0313", 0x1fe5: "\u03c1\u0314",
+	0x1fe6: "\u03c5\u0342", 0x1fe7: "\u03c5\u0308\u0342", 0x1fe8: "\u03a5\u0306", 0x1fe9: "\u03a5\u0304",
+	0x1fea: "\u03a5\u0300", 0x1feb: "\u03a5\u0301", 0x1fec: "\u03a1\u0314", 0x1fed: "\u00a8\u0300",
+	0x1fee: "\u00a8\u0301", 0x1fef: "`", 0x1ff2: "\u03c9\u0300\u0345", 0x1ff3: "\u03c9\u0345",
+	0x1ff4: "\u03c9\u0301\u0345", 0x1ff6: "\u03c9\u0342", 0x1ff7: "\u03c9\u0342\u0345", 0x1ff8: "\u039f\u0300",
+	0x1ff9: "\u039f\u0301", 0x1ffa: "\u03a9\u0300", 0x1ffb: "\u03a9\u0301", 0x1ffc: "\u03a9\u0345",
+	0x1ffd: "\u00b4", 0x2000: "\u2002", 0x2001: "\u2003", 0x2126: "\u03a9",
+	0x212a: "K", 0x212b: "A\u030a", 0x219a: "\u2190\u0338", 0x219b: "\u2192\u0338",
+	0x21ae: "\u2194\u0338", 0x21cd: "\u21d0\u0338", 0x21ce: "\u21d4\u0338", 0x21cf: "\u21d2\u0338",
+	0x2204: "\u2203\u0338", 0x2209: "\u2208\u0338", 0x220c: "\u220b\u0338", 0x2224: "\u2223\u0338",
+	0x2226: "\u2225\u0338", 0x2241: "\u223c\u0338", 0x2244: "\u2243\u0338", 0x2247: "\u2245\u0338",
+	0x2249: "\u2248\u0338", 0x2260: "=\u0338", 0x2262: "\u2261\u0338", 0x226d: "\u224d\u0338",
+	0x226e: "<\u0338", 0x226f: ">\u0338", 0x2270: "\u2264\u0338", 0x2271: "\u2265\u0338",
+	0x2274: "\u2272\u0338", 0x2275: "\u2273\u0338", 0x2278: "\u2276\u0338", 0x2279: "\u2277\u0338",
+	0x2280: "\u227a\u0338", 0x2281: "\u227b\u0338", 0x2284: "\u2282\u0338", 0x2285: "\u2283\u0338",
+	0x2288: "\u2286\u0338", 0x2289: "\u2287\u0338", 0x22ac: "\u22a2\u0338", 0x22ad: "\u22a8\u0338",
+	0x22ae: "\u22a9\u0338", 0x22af: "\u22ab\u0338", 0x22e0: "\u227c\u0338", 0x22e1: "\u227d\u0338",
+	0x22e2: "\u2291\u0338", 0x22e3: "\u2292\u0338", 0x22ea: "\u22b2\u0338", 0x22eb: "\u22b3\u0338",
+	0x22ec: "\u22b4\u0338", 0x22ed: "\u22b5\u0338", 0x2329: "\u3008", 0x232a: "\u3009",
+	0x2adc: "\u2add\u0338", 0x304c: "\u304b\u3099", 0x304e: "\u304d\u3099", 0x3050: "\u304f\u3099",
+	0x3052: "\u3051\u3099", 0x3054: "\u3053\u3099", 0x3056: "\u3055\u3099", 0x3058: "\u3057\u3099",
+	0x305a: "\u3059\u3099", 0x305c: "\u305b\u3099", 0x305e: "\u305d\u3099", 0x3060: "\u305f\u3099",
+	0x3062: "\u3061\u3099", 0x3065: "\u3064\u3099", 0x3067: "\u3066\u3099", 0x3069: "\u3068\u3099",
+	0x3070: "\u306f\u3099", 0x3071: "\u306f\u309a", 0x3073: "\u3072\u3099", 0x3074: "\u3072\u309a",
+	0x3076: "\u3075\u3099", 0x3077: "\u3075\u309a", 0x3079: "\u3078\u3099", 0x307a: "\u3078\u309a",
+	0x307c: "\u307b\u3099", 0x307d: "\u307b\u309a", 0x3094: "\u3046\u3099", 0x309e: "\u309d\u3099",
+	0x30ac: "\u30ab\u3099", 0x30ae: "\u30ad\u3099", 0x30b0: "\u30af\u3099", 0x30b2: "\u30b1\u3099",
+	0x30b4: "\u30b3\u3099", 0x30b6: "\u30b5\u3099", 0x30b8: "\u30b7\u3099", 0x30ba: "\u30b9\u3099",
+	0x30bc: "\u30bb\u3099", 0x30be: "\u30bd\u3099", 0x30c0: "\u30bf\u3099", 0x30c2: "\u30c1\u3099",
+	0x30c5: "\u30c4\u3099", 0x30c7: "\u30c6\u3099", 0x30c9: "\u30c8\u3099", 0x30d0: "\u30cf\u3099",
+	0x30d1: "\u30cf\u309a", 0x30d3: "\u30d2\u3099", 0x30d4: "\u30d2\u309a", 0x30d6: "\u30d5\u3099",
+	0x30d7: "\u30d5\u309a", 0x30d9: "\u30d8\u3099", 0x30da: "\u30d8\u309a", 0x30dc: "\u30db\u3099",
+	0x30dd: "\u30db\u309a", 0x30f4: "\u30a6\u3099", 0x30f7: "\u30ef\u3099", 0x30f8: "\u30f0\u3099",
+	0x30f9: "\u30f1\u3099", 0x30fa: "\u30f2\u3099", 0x30fe: "\u30fd\u3099", 0xf900: "\u8c48",
+	0xf901: "\u66f4", 0xf902: "\u8eca", 0xf903: "\u8cc8", 0xf904: "\u6ed1",
+	0xf905: "\u4e32", 0xf906: "\u53e5", 0xf907: "\u9f9c", 0xf908: "\u9f9c",
+	0xf909: "\u5951", 0xf90a: "\u91d1", 0xf90b: "\u5587", 0xf90c: "\u5948",
+	0xf90d: "\u61f6", 0xf90e: "\u7669", 0xf90f: "\u7f85", 0xf910: "\u863f",
+	0xf911: "\u87ba", 0xf912: "\u88f8", 0xf913: "\u908f", 0xf914: "\u6a02",
+	0xf915: "\u6d1b", 0xf916: "\u70d9", 0xf917: "\u73de", 0xf918: "\u843d",
+	0xf919: "\u916a", 0xf91a: "\u99f1", 0xf91b: "\u4e82", 0xf91c: "\u5375",
+	0xf91d: "\u6b04", 0xf91e: "\u721b", 0xf91f: "\u862d", 0xf920: "\u9e1e",
+	0xf921: "\u5d50", 0xf922: "\u6feb", 0xf923: "\u85cd", 0xf924: "\u8964",
+	0xf925: "\u62c9", 0xf926: "\u81d8", 0xf927: "\u881f", 0xf928: "\u5eca",
+	0xf929: "\u6717", 0xf92a: "\u6d6a", 0xf92b: "\u72fc", 0xf92c: "\u90ce",
+	0xf92d: "\u4f86", 0xf92e: "\u51b7", 0xf92f: "\u52de", 0xf930: "\u64c4",
+	0xf931: "\u6ad3", 0xf932: "\u7210", 0xf933: "\u76e7", 0xf934: "\u8001",
+	0xf935: "\u8606", 0xf936: "\u865c", 0xf937: "\u8def", 0xf938: "\u9732",
+	0xf939: "\u9b6f", 0xf93a: "\u9dfa", 0xf93b: "\u788c", 0xf93c: "\u797f",
+	0xf93d: "\u7da0", 0xf93e: "\u83c9", 0xf93f: "\u9304", 0xf940: "\u9e7f",
+	0xf941: "\u8ad6", 0xf942: "\u58df", 0xf943: "\u5f04", 0xf944: "\u7c60",
+	0xf945: "\u807e", 0xf946: "\u7262", 0xf947: "\u78ca", 0xf948: "\u8cc2",
+	0xf949: "\u96f7", 0xf94a: "\u58d8", 0xf94b: "\u5c62", 0xf94c: "\u6a13",
+	0xf94d: "\u6dda", 0xf94e: "\u6f0f", 0xf94f: "\u7d2f", 0xf950: "\u7e37",
+	0xf951: "\u964b", 0xf952: "\u52d2", 0xf953: "\u808b", 0xf954: "\u51dc",
+	0xf955: "\u51cc", 0xf956: "\u7a1c", 0xf957: "\u7dbe", 0xf958: "\u83f1",
+	0xf959: "\u9675", 0xf95a: "\u8b80", 0xf95b: "\u62cf", 0xf95c: "\u6a02",
+	0xf95d: "\u8afe", 0xf95e: "\u4e39", 0xf95f: "\u5be7", 0xf960: "\u6012",
+	0xf961: "\u7387", 0xf962: "\u7570", 0xf963: "\u5317", 0xf964: "\u78fb",
+	0xf965: "\u4fbf", 0xf966: "\u5fa9", 0xf967: "\u4e0d", 0xf968: "\u6ccc",
+	0xf969: "\u6578", 0xf96a: "\u7d22", 0xf96b: "\u53c3", 0xf96c: "\u585e",
+	0xf96d: "\u7701", 0xf96e: "\u8449", 0xf96f: "\u8aaa", 0xf970: "\u6bba",
+	0xf971: "\u8fb0", 0xf972: "\u6c88", 0xf973: "\u62fe", 0xf974: "\u82e5",
+	0xf975: "\u63a0", 0xf976: "\u7565", 0xf977: "\u4eae", 0xf978: "\u5169",
+	0xf979: "\u51c9", 0xf97a: "\u6881", 0xf97b: "\u7ce7", 0xf97c: "\u826f",
+	0xf97d: "\u8ad2", 0xf97e: "\u91cf", 0xf97f: "\u52f5", 0xf980: "\u5442",
+	0xf981: "\u5973", 0xf982: "\u5eec", 0xf983: "\u65c5", 0xf984: "\u6ffe",
+	0xf985: "\u792a", 0xf986: "\u95ad", 0xf987: "\u9a6a", 0xf988: "\u9e97",
+	0xf989: "\u9ece", 0xf98a: "\u529b", 0xf98b: "\u66c6", 0xf98c: "\u6b77",
+	0xf98d: "\u8f62", 0xf98e: "\u5e74", 0xf98f: "\u6190", 0xf990: "\u6200",
+	0xf991: "\u649a", 0xf992: "\u6f23", 0xf993: "\u7149", 0xf994: "\u7489",
+	0xf995: "\u79ca", 0xf996: "\u7df4", 0xf997: "\u806f", 0xf998: "\u8f26",
+	0xf999: "\u84ee", 0xf99a: "\u9023", 0xf99b: "\u934a", 0xf99c: "\u5217",
+	0xf99d: "\u52a3", 0xf99e: "\u54bd", 0xf99f: "\u70c8", 0xf9a0: "\u88c2",
+	0xf9a1: "\u8aaa", 0xf9a2: "\u5ec9", 0xf9a3: "\u5ff5", 0xf9a4: "\u637b",
+	0xf9a5: "\u6bae", 0xf9a6: "\u7c3e", 0xf9a7: "\u7375", 0xf9a8: "\u4ee4",
+	0xf9a9: "\u56f9", 0xf9aa: "\u5be7", 0xf9ab: "\u5dba", 0xf9ac: "\u601c",
+	0xf9ad: "\u73b2", 0xf9ae: "\u7469", 0xf9af: "\u7f9a", 0xf9b0: "\u8046",
+	0xf9b1: "\u9234", 0xf9b2: "\u96f6", 0xf9b3: "\u9748", 0xf9b4: "\u9818",
+	0xf9b5: "\u4f8b", 0xf9b6: "\u79ae", 0xf9b7: "\u91b4", 0xf9b8: "\u96b8",
+	0xf9b9: "\u60e1", 0xf9ba: "\u4e86", 0xf9bb: "\u50da", 0xf9bc: "\u5bee",
+	0xf9bd: "\u5c3f", 0xf9be: "\u6599", 0xf9bf: "\u6a02", 0xf9c0: "\u71ce",
+	0xf9c1: "\u7642", 0xf9c2: "\u84fc", 0xf9c3: "\u907c", 0xf9c4: "\u9f8d",
+	0xf9c5: "\u6688", 0xf9c6: "\u962e", 0xf9c7: "\u5289", 0xf9c8: "\u677b",
+	0xf9c9: "\u67f3", 0xf9ca: "\u6d41", 0xf9cb: "\u6e9c", 0xf9cc: "\u7409",
+	0xf9cd: "\u7559", 0xf9ce: "\u786b", 0xf9cf: "\u7d10", 0xf9d0: "\u985e",
+	0xf9d1: "\u516d", 0xf9d2: "\u622e", 0xf9d3: "\u9678", 0xf9d4: "\u502b",
+	0xf9d5: "\u5d19", 0xf9d6: "\u6dea", 0xf9d7: "\u8f2a", 0xf9d8: "\u5f8b",
+	0xf9d9: "\u6144", 0xf9da: "\u6817", 0xf9db: "\u7387", 0xf9dc: "\u9686",
+	0xf9dd: "\u5229", 0xf9de: "\u540f", 0xf9df: "\u5c65", 0xf9e0: "\u6613",
+	0xf9e1: "\u674e", 0xf9e2: "\u68a8", 0xf9e3: "\u6ce5", 0xf9e4: "\u7406",
+	0xf9e5: "\u75e2", 0xf9e6: "\u7f79", 0xf9e7: "\u88cf", 0xf9e8: "\u88e1",
+	0xf9e9: "\u91cc", 0xf9ea: "\u96e2", 0xf9eb: "\u533f", 0xf9ec: "\u6eba",
+	0xf9ed: "\u541d", 0xf9ee: "\u71d0", 0xf9ef: "\u7498", 0xf9f0: "\u85fa",
+	0xf9f1: "\u96a3", 0xf9f2: "\u9c57", 0xf9f3: "\u9e9f", 0xf9f4: "\u6797",
+	0xf9f5: "\u6dcb", 0xf9f6: "\u81e8", 0xf9f7: "\u7acb", 0xf9f8: "\u7b20",
+	0xf9f9: "\u7c92", 0xf9fa: "\u72c0", 0xf9fb: "\u7099", 0xf9fc: "\u8b58",
+	0xf9fd: "\u4ec0", 0xf9fe: "\u8336", 0xf9ff: "\u523a", 0xfa00: "\u5207",
+	0xfa01: "\u5ea6", 0xfa02: "\u62d3", 0xfa03: "\u7cd6", 0xfa04: "\u5b85",
+	0xfa05: "\u6d1e", 0xfa06: "\u66b4", 0xfa07: "\u8f3b", 0xfa08: "\u884c",
+	0xfa09: "\u964d", 0xfa0a: "\u898b", 0xfa0b: "\u5ed3", 0xfa0c: "\u5140",
+	0xfa0d: "\u55c0", 0xfa10: "\u585a", 0xfa12: "\u6674", 0xfa15: "\u51de",
+	0xfa16: "\u732a", 0xfa17: "\u76ca", 0xfa18: "\u793c", 0xfa19: "\u795e",
+	0xfa1a: "\u7965", 0xfa1b: "\u798f", 0xfa1c: "\u9756", 0xfa1d: "\u7cbe",
+	0xfa1e: "\u7fbd", 0xfa20: "\u8612", 0xfa22: "\u8af8", 0xfa25: "\u9038",
+	0xfa26: "\u90fd", 0xfa2a: "\u98ef", 0xfa2b: "\u98fc", 0xfa2c: "\u9928",
+	0xfa2d: "\u9db4", 0xfa2e: "\u90de", 0xfa2f: "\u96b7", 0xfa30: "\u4fae",
+	0xfa31: "\u50e7", 0xfa32: "\u514d", 0xfa33: "\u52c9", 0xfa34: "\u52e4",
+	0xfa35: "\u5351", 0xfa36: "\u559d", 0xfa37: "\u5606", 0xfa38: "\u5668",
+	0xfa39: "\u5840", 0xfa3a: "\u58a8", 0xfa3b: "\u5c64", 0xfa3c: "\u5c6e",
+	0xfa3d: "\u6094", 0xfa3e: "\u6168", 0xfa3f: "\u618e", 0xfa40: "\u61f2",
+	0xfa41: "\u654f", 0xfa42: "\u65e2", 0xfa43: "\u6691", 0xfa44: "\u6885",
+	0xfa45: "\u6d77", 0xfa46: "\u6e1a", 0xfa47: "\u6f22", 0xfa48: "\u716e",
+	0xfa49: "\u722b", 0xfa4a: "\u7422", 0xfa4b: "\u7891", 0xfa4c: "\u793e",
+	0xfa4d: "\u7949", 0xfa4e: "\u7948", 0xfa4f: "\u7950", 0xfa50: "\u7956",
+	0xfa51: "\u795d", 0xfa52: "\u798d", 0xfa53: "\u798e", 0xfa54: "\u7a40",
+	0xfa55: "\u7a81", 0xfa56: "\u7bc0", 0xfa57: "\u7df4", 0xfa58: "\u7e09",
+	0xfa59: "\u7e41", 0xfa5a: "\u7f72", 0xfa5b: "\u8005", 0xfa5c: "\u81ed",
+	0xfa5d: "\u8279", 0xfa5e: "\u8279", 0xfa5f: "\u8457", 0xfa60: "\u8910",
+	0xfa61: "\u8996", 0xfa62: "\u8b01", 0xfa63: "\u8b39", 0xfa64: "\u8cd3",
+	0xfa65: "\u8d08", 0xfa66: "\u8fb6", 0xfa67: "\u9038", 0xfa68: "\u96e3",
+	0xfa69: "\u97ff", 0xfa6a: "\u983b", 0xfa6b: "\u6075", 0xfa6c: "\U000242ee",
+	0xfa6d: "\u8218", 0xfa70: "\u4e26", 0xfa71: "\u51b5", 0xfa72: "\u5168",
+	0xfa73: "\u4f80", 0xfa74: "\u5145", 0xfa75: "\u5180", 0xfa76: "\u52c7",
+	0xfa77: "\u52fa", 0xfa78: "\u559d", 0xfa79: "\u5555", 0xfa7a: "\u5599",
+	0xfa7b: "\u55e2", 0xfa7c: "\u585a", 0xfa7d: "\u58b3", 0xfa7e: "\u5944",
+	0xfa7f: "\u5954", 0xfa80: "\u5a62", 0xfa81: "\u5b28", 0xfa82: "\u5ed2",
+	0xfa83: "\u5ed9", 0xfa84: "\u5f69", 0xfa85: "\u5fad", 0xfa86: "\u60d8",
+	0xfa87: "\u614e", 0xfa88: "\u6108", 0xfa89: "\u618e", 0xfa8a: "\u6160",
+	0xfa8b: "\u61f2", 0xfa8c: "\u6234", 0xfa8d: "\u63c4", 0xfa8e: "\u641c",
+	0xfa8f: "\u6452", 0xfa90: "\u6556", 0xfa91: "\u6674", 0xfa92: "\u6717",
+	0xfa93: "\u671b", 0xfa94: "\u6756", 0xfa95: "\u6b79", 0xfa96: "\u6bba",
+	0xfa97: "\u6d41", 0xfa98: "\u6edb", 0xfa99: "\u6ecb", 0xfa9a: "\u6f22",
+	0xfa9b: "\u701e", 0xfa9c: "\u716e", 0xfa9d: "\u77a7", 0xfa9e: "\u7235",
+	0xfa9f: "\u72af", 0xfaa0: "\u732a", 0xfaa1: "\u7471", 0xfaa2: "\u7506",
+	0xfaa3: "\u753b", 0xfaa4: "\u761d", 0xfaa5: "\u761f", 0xfaa6: "\u76ca",
+	0xfaa7: "\u76db", 0xfaa8: "\u76f4", 0xfaa9: "\u774a", 0xfaaa: "\u7740",
+	0xfaab: "\u78cc", 0xfaac: "\u7ab1", 0xfaad: "\u7bc0", 0xfaae: "\u7c7b",
+	0xfaaf: "\u7d5b", 0xfab0: "\u7df4", 0xfab1: "\u7f3e", 0xfab2: "\u8005",
+	0xfab3: "\u8352", 0xfab4: "\u83ef", 0xfab5: "\u8779", 0xfab6: "\u8941",
+	0xfab7: "\u8986", 0xfab8: "\u8996", 0xfab9: "\u8abf", 0xfaba: "\u8af8",
+	0xfabb: "\u8acb", 0xfabc: "\u8b01", 0xfabd: "\u8afe", 0xfabe: "\u8aed",
+	0xfabf: "\u8b39", 0xfac0: "\u8b8a", 0xfac1: "\u8d08", 0xfac2: "\u8f38",
+	0xfac3: "\u9072", 0xfac4: "\u9199", 0xfac5: "\u9276", 0xfac6: "\u967c",
+	0xfac7: "\u96e3", 0xfac8: "\u9756", 0xfac9: "\u97db", 0xfaca: "\u97ff",
+	0xfacb: "\u980b", 0xfacc: "\u983b", 0xfacd: "\u9b12", 0xface: "\u9f9c",
+	0xfacf: "\U0002284a", 0xfad0: "\U00022844", 0xfad1: "\U000233d5", 0xfad2: "\u3b9d",
+	0xfad3: "\u4018", 0xfad4: "\u4039", 0xfad5: "\U00025249", 0xfad6: "\U00025cd0",
+	0xfad7: "\U00027ed3", 0xfad8: "\u9f43", 0xfad9: "\u9f8e", 0xfb1d: "\u05d9\u05b4",
+	0xfb1f: "\u05f2\u05b7", 0xfb2a: "\u05e9\u05c1", 0xfb2b: "\u05e9\u05c2", 0xfb2c: "\u05e9\u05bc\u05c1",
+	0xfb2d: "\u05e9\u05bc\u05c2", 0xfb2e: "\u05d0\u05b7", 0xfb2f: "\u05d0\u05b8", 0xfb30: "\u05d0\u05bc",
+	0xfb31: "\u05d1\u05bc", 0xfb32: "\u05d2\u05bc", 0xfb33: "\u05d3\u05bc", 0xfb34: "\u05d4\u05bc",
+	0xfb35: "\u05d5\u05bc", 0xfb36: "\u05d6\u05bc", 0xfb38: "\u05d8\u05bc", 0xfb39: "\u05d9\u05bc",
+	0xfb3a: "\u05da\u05bc", 0xfb3b: "\u05db\u05bc", 0xfb3c: "\u05dc\u05bc", 0xfb3e: "\u05de\u05bc",
+	0xfb40: "\u05e0\u05bc", 0xfb41: "\u05e1\u05bc", 0xfb43: "\u05e3\u05bc", 0xfb44: "\u05e4\u05bc",
+	0xfb46: "\u05e6\u05bc", 0xfb47: "\u05e7\u05bc", 0xfb48: "\u05e8\u05bc", 0xfb49: "\u05e9\u05bc",
+	0xfb4a: "\u05ea\u05bc", 0xfb4b: "\u05d5\u05b9", 0xfb4c: "\u05d1\u05bf", 0xfb4d: "\u05db\u05bf",
+	0xfb4e: "\u05e4\u05bf", 0x1109a: "\U00011099\U000110ba", 0x1109c: "\U0001109b\U000110ba", 0x110ab: "\U000110a5\U000110ba",
+	0x1112e: "\U00011131\U00011127", 0x1112f: "\U00011132\U00011127", 0x1134b: "\U00011347\U0001133e", 0x1134c: "\U00011347\U00011357",
+	0x114bb: "\U000114b9\U000114ba", 0x114bc: "\U000114b9\U000114b0", 0x114be: "\U000114b9\U000114bd", 0x115ba: "\U000115b8\U000115af",
+	0x115bb: "\U000115b9\U000115af", 0x11938: "\U00011935\U00011930", 0x1d15e: "\U0001d157\U0001d165", 0x1d15f: "\U0001d158\U0001d165",
+	0x1d160: "\U0001d158\U0001d165\U0001d16e", 0x1d161: "\U0001d158\U0001d165\U0001d16f", 0x1d162: "\U0001d158\U0001d165\U0001d170", 0x1d163: "\U0001d158\U0001d165\U0001d171",
+	0x1d164: "\U0001d158\U0001d165\U0001d172", 0x1d1bb: "\U0001d1b9\U0001d165", 0x1d1bc: "\U0001d1ba\U0001d165", 0x1d1bd: "\U0001d1b9\U0001d165\U0001d16e",
+	0x1d1be: "\U0001d1ba\U0001d165\U0001d16e", 0x1d1bf: "\U0001d1b9\U0001d165\U0001d16f", 0x1d1c0: "\U0001d1ba\U0001d165\U0001d16f", 0x2f800: "\u4e3d",
+	0x2f801: "\u4e38", 0x2f802: "\u4e41", 0x2f803: "\U00020122", 0x2f804: "\u4f60",
+	0x2f805: "\u4fae", 0x2f806: "\u4fbb", 0x2f807: "\u5002", 0x2f808: "\u507a",
+	0x2f809: "\u5099", 0x2f80a: "\u50e7", 0x2f80b: "\u50cf", 0x2f80c: "\u349e",
+	0x2f80d: "\U0002063a", 0x2f80e: "\u514d", 0x2f80f: "\u5154", 0x2f810: "\u5164",
+	0x2f811: "\u5177", 0x2f812: "\U0002051c", 0x2f813: "\u34b9", 0x2f814: "\u5167",
+	0x2f815: "\u518d", 0x2f816: "\U0002054b", 0x2f817: "\u5197", 0x2f818: "\u51a4",
+	0x2f819: "\u4ecc", 0x2f81a: "\u51ac", 0x2f81b: "\u51b5", 0x2f81c: "\U000291df",
+	0x2f81d: "\u51f5", 0x2f81e: "\u5203", 0x2f81f: "\u34df", 0x2f820: "\u523b",
+	0x2f821: "\u5246", 0x2f822: "\u5272", 0x2f823: "\u5277", 0x2f824: "\u3515",
+	0x2f825: "\u52c7", 0x2f826: "\u52c9", 0x2f827: "\u52e4", 0x2f828: "\u52fa",
+	0x2f829: "\u5305", 0x2f82a: "\u5306", 0x2f82b: "\u5317", 0x2f82c: "\u5349",
+	0x2f82d: "\u5351", 0x2f82e: "\u535a", 0x2f82f: "\u5373", 0x2f830: "\u537d",
+	0x2f831: "\u537f", 0x2f832: "\u537f", 0x2f833: "\u537f", 0x2f834: "\U00020a2c",
+	0x2f835: "\u7070", 0x2f836: "\u53ca", 0x2f837: "\u53df", 0x2f838: "\U00020b63",
+	0x2f839: "\u53eb", 0x2f83a: "\u53f1", 0x2f83b: "\u5406", 0x2f83c: "\u549e",
+	0x2f83d: "\u5438", 0x2f83e: "\u5448", 0x2f83f: "\u5468", 0x2f840: "\u54a2",
+	0x2f841: "\u54f6", 0x2f842: "\u5510", 0x2f843: "\u5553", 0x2f844: "\u5563",
+	0x2f845: "\u5584", 0x2f846: "\u5584", 0x2f847: "\u5599", 0x2f848: "\u55ab",
+	0x2f849: "\u55b3", 0x2f84a: "\u55c2", 0x2f84b: "\u5716", 0x2f84c: "\u5606",
+	0x2f84d: "\u5717", 0x2f84e: "\u5651", 0x2f84f: "\u5674", 0x2f850: "\u5207",
+	0x2f851: "\u58ee", 0x2f852: "\u57ce", 0x2f853: "\u57f4", 0x2f854: "\u580d",
+	0x2f855: "\u578b", 0x2f856: "\u5832", 0x2f857: "\u5831", 0x2f858: "\u58ac",
+	0x2f859: "\U000214e4", 0x2f85a: "\u58f2", 0x2f85b: "\u58f7", 0x2f85c: "\u5906",
+	0x2f85d: "\u591a", 0x2f85e: "\u5922", 0x2f85f: "\u5962", 0x2f860: "\U000216a8",
+	0x2f861: "\U000216ea", 0x2f862: "\u59ec", 0x2f863: "\u5a1b", 0x2f864: "\u5a27",
+	0x2f865: "\u59d8", 0x2f866: "\u5a66", 0x2f867: "\u36ee", 0x2f868: "\u36fc",
+	0x2f869: "\u5b08", 0x2f86a: "\u5b3e", 0x2f86b: "\u5b3e", 0x2f86c: "\U000219c8",
+	0x2f86d: "\u5bc3", 0x2f86e: "\u5bd8", 0x2f86f: "\u5be7", 0x2f870: "\u5bf3",
+	0x2f871: "\U00021b18", 0x2f872: "\u5bff", 0x2f873: "\u5c06", 0x2f874: "\u5f53",
+	0x2f875: "\u5c22", 0x2f876: "\u3781", 0x2f877: "\u5c60", 0x2f878: "\u5c6e",
+	0x2f879: "\u5cc0", 0x2f87a: "\u5c8d", 0x2f87b: "\U00021de4", 0x2f87c: "\u5d43",
+	0x2f87d: "\U00021de6", 0x2f87e: "\u5d6e", 0x2f87f: "\u5d6b", 0x2f880: "\u5d7c",
+	0x2f881: "\u5de1", 0x2f882: "\u5de2", 0x2f883: "\u382f", 0x2f884: "\u5dfd",
+	0x2f885: "\u5e28", 0x2f886: "\u5e3d", 0x2f887: "\u5e69", 0x2f888: "\u3862",
+	0x2f889: "\U00022183", 0x2f88a: "\u387c", 0x2f88b: "\u5eb0", 0x2f88c: "\u5eb3",
+	0x2f88d: "\u5eb6", 0x2f88e: "\u5eca", 0x2f88f: "\U0002a392", 0x2f890: "\u5efe",
+	0x2f891: "\U00022331", 0x2f892: "\U00022331", 0x2f893: "\u8201", 0x2f894: "\u5f22",
+	0x2f895: "\u5f22", 0x2f896: "\u38c7", 0x2f897: "\U000232b8", 0x2f898: "\U000261da",
+	0x2f899: "\u5f62", 0x2f89a: "\u5f6b", 0x2f89b: "\u38e3", 0x2f89c: "\u5f9a",
+	0x2f89d: "\u5fcd", 0x2f89e: "\u5fd7", 0x2f89f: "\u5ff9", 0x2f8a0: "\u6081",
+	0x2f8a1: "\u393a", 0x2f8a2: "\u391c", 0x2f8a3: "\u6094", 0x2f8a4: "\U000226d4",
+	0x2f8a5: "\u60c7", 0x2f8a6: "\u6148", 0x2f8a7: "\u614c", 0x2f8a8: "\u614e",
+	0x2f8a9: "\u614c", 0x2f8aa: "\u617a", 0x2f8ab: "\u618e", 0x2f8ac: "\u61b2",
+	0x2f8ad: "\u61a4", 0x2f8ae: "\u61af", 0x2f8af: "\u61de", 0x2f8b0: "\u61f2",
+	0x2f8b1: "\u61f6", 0x2f8b2: "\u6210", 0x2f8b3: "\u621b", 0x2f8b4: "\u625d",
+	0x2f8b5: "\u62b1", 0x2f8b6: "\u62d4", 0x2f8b7: "\u6350", 0x2f8b8: "\U00022b0c",
+	0x2f8b9: "\u633d", 0x2f8ba: "\u62fc", 0x2f8bb: "\u6368", 0x2f8bc: "\u6383",
+	0x2f8bd: "\u63e4", 0x2f8be: "\U00022bf1", 0x2f8bf: "\u6422", 0x2f8c0: "\u63c5",
+	0x2f8c1: "\u63a9", 0x2f8c2: "\u3a2e", 0x2f8c3: "\u6469", 0x2f8c4: "\u647e",
+	0x2f8c5: "\u649d", 0x2f8c6: "\u6477", 0x2f8c7: "\u3a6c", 0x2f8c8: "\u654f",
+	0x2f8c9: "\u656c", 0x2f8ca: "\U0002300a", 0x2f8cb: "\u65e3", 0x2f8cc: "\u66f8",
+	0x2f8cd: "\u6649", 0x2f8ce: "\u3b19", 0x2f8cf: "\u6691", 0x2f8d0: "\u3b08",
+	0x2f8d1: "\u3ae4", 0x2f8d2: "\u5192", 0x2f8d3: "\u5195", 0x2f8d4: "\u6700",
+	0x2f8d5: "\u669c", 0x2f8d6: "\u80ad", 0x2f8d7: "\u43d9", 0x2f8d8: "\u6717",
+	0x2f8d9: "\u671b", 0x2f8da: "\u6721", 0x2f8db: "\u675e", 0x2f8dc: "\u6753",
+	0x2f8dd: "\U000233c3", 0x2f8de: "\u3b49", 0x2f8df: "\u67fa", 0x2f8e0: "\u6785",
+	0x2f8e1: "\u6852", 0x2f8e2: "\u6885", 0x2f8e3: "\U0002346d", 0x2f8e4: "\u688e",
+	0x2f8e5: "\u681f", 0x2f8e6: "\u6914", 0x2f8e7: "\u3b9d", 0x2f8e8: "\u6942",
+	0x2f8e9: "\u69a3", 0x2f8ea: "\u69ea", 0x2f8eb: "\u6aa8", 0x2f8ec: "\U000236a3",
+	0x2f8ed: "\u6adb", 0x2f8ee: "\u3c18", 0x2f8ef: "\u6b21", 0x2f8f0: "\U000238a7",
+	0x2f8f1: "\u6b54", 0x2f8f2: "\u3c4e", 0x2f8f3: "\u6b72", 0x2f8f4: "\u6b9f",
+	0x2f8f5: "\u6bba", 0x2f8f6: "\u6bbb", 0x2f8f7: "\U00023a8d", 0x2f8f8: "\U00021d0b",
+	0x2f8f9: "\U00023afa", 0x2f8fa: "\u6c4e", 0x2f8fb: "\U00023cbc", 0x2f8fc: "\u6cbf",
+	0x2f8fd: "\u6ccd", 0x2f8fe: "\u6c67", 0x2f8ff: "\u6d16", 0x2f900: "\u6d3e",
+	0x2f901: "\u6d77", 0x2f902: "\u6d41", 0x2f903: "\u6d69", 0x2f904: "\u6d78",
+	0x2f905: "\u6d85", 0x2f906: "\U00023d1e", 0x2f907: "\u6d34", 0x2f908: "\u6e2f",
+	0x2f909: "\u6e6e", 0x2f90a: "\u3d33", 0x2f90b: "\u6ecb", 0x2f90c: "\u6ec7",
+	0x2f90d: "\U00023ed1", 0x2f90e: "\u6df9", 0x2f90f: "\u6f6e", 0x2f910: "\U00023f5e",
+	0x2f911: "\U00023f8e", 0x2f912: "\u6fc6", 0x2f913: "\u7039", 0x2f914: "\u701e",
+	0x2f915: "\u701b", 0x2f916: "\u3d96", 0x2f917: "\u704a", 0x2f918: "\u707d",
+	0x2f919: "\u7077", 0x2f91a: "\u70ad", 0x2f91b: "\U00020525", 0x2f91c: "\u7145",
+	0x2f91d: "\U00024263", 0x2f91e: "\u719c", 0x2f91f: "\U000243ab", 0x2f920: "\u7228",
+	0x2f921: "\u7235", 0x2f922: "\u7250", 0x2f923: "\U00024608", 0x2f924: "\u7280",
+	0x2f925: "\u7295", 0x2f926: "\U00024735", 0x2f927: "\U00024814", 0x2f928: "\u737a",
+	0x2f929: "\u738b", 0x2f92a: "\u3eac", 0x2f92b: "\u73a5", 0x2f92c: "\u3eb8",
+	0x2f92d: "\u3eb8", 0x2f92e: "\u7447", 0x2f92f: "\u745c", 0x2f930: "\u7471",
+	0x2f931: "\u7485", 0x2f932: "\u74ca", 0x2f933: "\u3f1b", 0x2f934: "\u7524",
+	0x2f935: "\U00024c36", 0x2f936: "\u753e", 0x2f937: "\U00024c92", 0x2f938: "\u7570",
+	0x2f939: "\U0002219f", 0x2f93a: "\u7610", 0x2f93b: "\U00024fa1", 0x2f93c: "\U00024fb8",
+	0x2f93d: "\U00025044", 0x2f93e: "\u3ffc", 0x2f93f: "\u4008", 0x2f940: "\u76f4",
+	0x2f941: "\U000250f3", 0x2f942: "\U000250f2", 0x2f943: "\U00025119", 0x2f944: "\U00025133",
+	0x2f945: "\u771e", 0x2f946: "\u771f", 0x2f947: "\u771f", 0x2f948: "\u774a",
+	0x2f949: "\u4039", 0x2f94a: "\u778b", 0x2f94b: "\u4046", 0x2f94c: "\u4096",
+	0x2f94d: "\U0002541d", 0x2f94e: "\u784e", 0x2f94f: "\u788c", 0x2f950: "\u78cc",
+	0x2f951: "\u40e3", 0x2f952: "\U00025626", 0x2f953: "\u7956", 0x2f954: "\U0002569a",
+	0x2f955: "\U000256c5", 0x2f956: "\u798f", 0x2f957: "\u79eb", 0x2f958: "\u412f",
+	0x2f959: "\u7a40", 0x2f95a: "\u7a4a", 0x2f95b: "\u7a4f", 0x2f95c: "\U0002597c",
+	0x2f95d: "\U00025aa7", 0x2f95e: "\U00025aa7", 0x2f95f: "\u7aee", 0x2f960: "\u4202",
+	0x2f961: "\U00025bab", 0x2f962: "\u7bc6", 0x2f963: "\u7bc9", 0x2f964: "\u4227",
+	0x2f965: "\U00025c80", 0x2f966: "\u7cd2", 0x2f967: "\u42a0", 0x2f968: "\u7ce8",
+	0x2f969: "\u7ce3", 0x2f96a: "\u7d00", 0x2f96b: "\U00025f86", 0x2f96c: "\u7d63",
+	0x2f96d: "\u4301", 0x2f96e: "\u7dc7", 0x2f96f: "\u7e02", 0x2f970: "\u7e45",
+	0x2f971: "\u4334", 0x2f972: "\U00026228", 0x2f973: "\U00026247", 0x2f974: "\u4359",
+	0x2f975: "\U000262d9", 0x2f976: "\u7f7a", 0x2f977: "\U0002633e", 0x2f978: "\u7f95",
+	0x2f979: "\u7ffa", 0x2f97a: "\u8005", 0x2f97b: "\U000264da", 0x2f97c: "\U00026523",
+	0x2f97d: "\u8060", 0x2f97e: "\U000265a8", 0x2f97f: "\u8070", 0x2f980: "\U0002335f",
+	0x2f981: "\u43d5", 0x2f982: "\u80b2", 0x2f983: "\u8103", 0x2f984: "\u440b",
+	0x2f985: "\u813e", 0x2f986: "\u5ab5", 0x2f987: "\U000267a7", 0x2f988: "\U000267b5",
+	0x2f989: "\U00023393", 0x2f98a: "\U0002339c", 0x2f98b: "\u8201", 0x2f98c: "\u8204",
+	0x2f98d: "\u8f9e", 0x2f98e: "\u446b", 0x2f98f: "\u8291", 0x2f990: "\u828b",
+	0x2f991: "\u829d", 0x2f992: "\u52b3", 0x2f993: "\u82b1", 0x2f994: "\u82b3",
+	0x2f995: "\u82bd", 0x2f996: "\u82e6", 0x2f997: "\U00026b3c", 0x2f998: "\u82e5",
+	0x2f999: "\u831d", 0x2f99a: "\u8363", 0x2f99b: "\u83ad", 0x2f99c: "\u8323",
+	0x2f99d: "\u83bd", 0x2f99e: "\u83e7", 0x2f99f: "\u8457", 0x2f9a0: "\u8353",
+	0x2f9a1: "\u83ca", 0x2f9a2: "\u83cc", 0x2f9a3: "\u83dc", 0x2f9a4: "\U00026c36",
+	0x2f9a5: "\U00026d6b", 0x2f9a6: "\U00026cd5", 0x2f9a7: "\u452b", 0x2f9a8: "\u84f1",
+	0x2f9a9: "\u84f3", 0x2f9aa: "\u8516", 0x2f9ab: "\U000273ca", 0x2f9ac: "\u8564",
+	0x2f9ad: "\U00026f2c", 0x2f9ae: "\u455d", 0x2f9af: "\u4561", 0x2f9b0: "\U00026fb1",
+	0x2f9b1: "\U000270d2", 0x2f9b2: "\u456b", 0x2f9b3: "\u8650", 0x2f9b4: "\u865c",
+	0x2f9b5: "\u8667", 0x2f9b6: "\u8669", 0x2f9b7: "\u86a9", 0x2f9b8: "\u8688",
+	0x2f9b9: "\u870e", 0x2f9ba: "\u86e2", 0x2f9bb: "\u8779", 0x2f9bc: "\u8728",
+	0x2f9bd: "\u876b", 0x2f9be: "\u8786", 0x2f9bf: "\u45d7", 0x2f9c0: "\u87e1",
+	0x2f9c1: "\u8801", 0x2f9c2: "\u45f9", 0x2f9c3: "\u8860", 0x2f9c4: "\u8863",
+	0x2f9c5: "\U00027667", 0x2f9c6: "\u88d7", 0x2f9c7: "\u88de", 0x2f9c8: "\u4635",
+	0x2f9c9: "\u88fa", 0x2f9ca: "\u34bb", 0x2f9cb: "\U000278ae", 0x2f9cc: "\U00027966",
+	0x2f9cd: "\u46be", 0x2f9ce: "\u46c7", 0x2f9cf: "\u8aa0", 0x2f9d0: "\u8aed",
+	0x2f9d1: "\u8b8a", 0x2f9d2: "\u8c55", 0x2f9d3: "\U00027ca8", 0x2f9d4: "\u8cab",
+	0x2f9d5: "\u8cc1", 0x2f9d6: "\u8d1b", 0x2f9d7: "\u8d77", 0x2f9d8: "\U00027f2f",
+	0x2f9d9: "\U00020804", 0x2f9da: "\u8dcb", 0x2f9db: "\u8dbc", 0x2f9dc: "\u8df0",
+	0x2f9dd: "\U000208de", 0x2f9de: "\u8ed4", 0x2f9df: "\u8f38", 0x2f9e0: "\U000285d2",
+	0x2f9e1: "\U000285ed", 0x2f9e2: "\u9094", 0x2f9e3: "\u90f1", 0x2f9e4: "\u9111",
+	0x2f9e5: "\U0002872e", 0x2f9e6: "\u911b", 0x2f9e7: "\u9238", 0x2f9e8: "\u92d7",
+	0x2f9e9: "\u92d8", 0x2f9ea: "\u927c", 0x2f9eb: "\u93f9", 0x2f9ec: "\u9415",
+	0x2f9ed: "\U00028bfa", 0x2f9ee: "\u958b", 0x2f9ef: "\u4995", 0x2f9f0: "\u95b7",
+	0x2f9f1: "\U00028d77", 0x2f9f2: "\u49e6", 0x2f9f3: "\u96c3", 0x2f9f4: "\u5db2",
+	0x2f9f5: "\u9723", 0x2f9f6: "\U00029145", 0x2f9f7: "\U0002921a", 0x2f9f8: "\u4a6e",
+	0x2f9f9: "\u4a76", 0x2f9fa: "\u97e0", 0x2f9fb: "\U0002940a", 0x2f9fc: "\u4ab2",
+	0x2f9fd: "\U00029496", 0x2f9fe: "\u980b", 0x2f9ff: "\u980b", 0x2fa00: "\u9829",
+	0x2fa01: "\U000295b6", 0x2fa02: "\u98e2", 0x2fa03: "\u4b33", 0x2fa04: "\u9929",
+	0x2fa05: "\u99a7", 0x2fa06: "\u99c2", 0x2fa07: "\u99fe", 0x2fa08: "\u4bce",
+	0x2fa09: "\U00029b30", 0x2fa0a: "\u9b12", 0x2fa0b: "\u9c40", 0x2fa0c: "\u9cfd",
+	0x2fa0d: "\u4cce", 0x2fa0e: "\u4ced", 0x2fa0f: "\u9d67", 0x2fa10: "\U0002a0ce",
+	0x2fa11: "\u4cf8", 0x2fa12: "\U0002a105", 0x2fa13: "\U0002a20e", 0x2fa14: "\U0002a291",
+	0x2fa15: "\u9ebb", 0x2fa16: "\u4d56", 0x2fa17: "\u9ef9", 0x2fa18: "\u9efe",
+	0x2fa19: "\u9f05", 0x2fa1a: "\u9f0f", 0x2fa1b: "\u9f16", 0x2fa1c: "\u9f3b",
+	0x2fa1d: "\U0002a600",
+}
+
+// combiningClasses maps characters to their non-zero canonical combining
+// classes.
+var combiningClasses = map[rune]uint8{
+	0x0300: 230, 0x0301: 230, 0x0302: 230, 0x0303: 230, 0x0304: 230, 0x0305: 230, 0x0306: 230, 0x0307: 230,
+	0x0308: 230, 0x0309: 230, 0x030a: 230, 0x030b: 230, 0x030c: 230, 0x030d: 230, 0x030e: 230, 0x030f: 230,
+	0x0310: 230, 0x0311: 230, 0x0312: 230, 0x0313: 230, 0x0314: 230, 0x0315: 232, 0x0316: 220, 0x0317: 220,
+	0x0318: 220, 0x0319: 220, 0x031a: 232, 0x031b: 216, 0x031c: 220, 0x031d: 220, 0x031e: 220, 0x031f: 220,
+	0x0320: 220, 0x0321: 202, 0x0322: 202, 0x0323: 220, 0x0324: 220, 0x0325: 220, 0x0326: 220, 0x0327: 202,
+	0x0328: 202, 0x0329: 220, 0x032a: 220, 0x032b: 220, 0x032c: 220, 0x032d: 220, 0x032e: 220, 0x032f: 220,
+	0x0330: 220, 0x0331: 220, 0x0332: 220, 0x0333: 220, 0x0334: 1, 0x0335: 1, 0x0336: 1, 0x0337: 1,
+	0x0338: 1, 0x0339: 220, 0x033a: 220, 0x033b: 220, 0x033c: 220, 0x033d: 230, 0x033e: 230, 0x033f: 230,
+	0x0340: 230, 0x0341: 230, 0x0342: 230, 0x0343: 230, 0x0344: 230, 0x0345: 240, 0x0346: 230, 0x0347: 220,
+	0x0348: 220, 0x0349: 220, 0x034a: 230, 0x034b: 230, 0x034c: 230, 0x034d: 220, 0x034e: 220, 0x0350: 230,
+	0x0351: 230, 0x0352: 230, 0x0353: 220, 0x0354: 220, 0x0355: 220, 0x0356: 220, 0x0357: 230, 0x0358: 232,
+	0x0359: 220, 0x035a: 220, 0x035b: 230, 0x035c: 233, 0x035d: 234, 0x035e: 234, 0x035f: 233, 0x0360: 234,
+	0x0361: 234, 0x0362: 233, 0x0363: 230, 0x0364: 230, 0x0365: 230, 0x0366: 230, 0x0367: 230, 0x0368: 230,
+	0x0369: 230, 0x036a: 230, 0x036b: 230, 0x036c: 230, 0x036d: 230, 0x036e: 230, 0x036f: 230, 0x0483: 230,
+	0x0484: 230, 0x0485: 230, 0x0486: 230, 0x0487: 230, 0x0591: 220, 0x0592: 230, 0x0593: 230, 0x0594: 230,
+	0x0595: 230, 0x0596: 220, 0x0597: 230, 0x0598: 230, 0x0599: 230, 0x059a: 222, 0x059b: 220, 0x059c: 230,
+	0x059d: 230, 0x059e: 230, 0x059f: 230, 0x05a0: 230, 0x05a1: 230, 0x05a2: 220, 0x05a3: 220, 0x05a4: 220,
+	0x05a5: 220, 0x05a6: 220, 0x05a7: 220, 0x05a8: 230, 0x05a9: 230, 0x05aa: 220, 0x05ab: 230, 0x05ac: 230,
+	0x05ad: 222, 0x05ae: 228, 0x05af: 230, 0x05b0: 10, 0x05b1: 11, 0x05b2: 12, 0x05b3: 13, 0x05b4: 14,
+	0x05b5: 15, 0x05b6: 16, 0x05b7: 17, 0x05b8: 18, 0x05b9: 19, 0x05ba: 19, 0x05bb: 20, 0x05bc: 21,
+	0x05bd: 22, 0x05bf: 23, 0x05c1: 24, 0x05c2: 25, 0x05c4: 230, 0x05c5: 220, 0x05c7: 18, 0x0610: 230,
+	0x0611: 230, 0x0612: 230, 0x0613: 230, 0x0614: 230, 0x0615: 230, 0x0616: 230, 0x0617: 230, 0x0618: 30,
+	0x0619: 31, 0x061a: 32, 0x064b: 27, 0x064c: 28, 0x064d: 29, 0x064e: 30, 0x064f: 31, 0x0650: 32,
+	0x0651: 33, 0x0652: 34, 0x0653: 230, 0x0654: 230, 0x0655: 220, 0x0656: 220, 0x0657: 230, 0x0658: 230,
+	0x0659: 230, 0x065a: 230, 0x065b: 230, 0x065c: 220, 0x065d: 230, 0x065e: 230, 0x065f: 220, 0x0670: 35,
+	0x06d6: 230, 0x06d7: 230, 0x06d8: 230, 0x06d9: 230, 0x06da: 230, 0x06db: 230, 0x06dc: 230, 0x06df: 230,
+	0x06e0: 230, 0x06e1: 230, 0x06e2: 230, 0x06e3: 220, 0x06e4: 230, 0x06e7: 230, 0x06e8: 230, 0x06ea: 220,
+	0x06eb: 230, 0x06ec: 230, 0x06ed: 220, 0x0711: 36, 0x0730: 230, 0x0731: 220, 0x0732: 230, 0x0733: 230,
+	0x0734: 220, 0x0735: 230, 0x0736: 230, 0x0737: 220, 0x0738: 220, 0x0739: 220, 0x073a: 230, 0x073b: 220,
+	0x073c: 220, 0x073d: 230, 0x073e: 220, 0x073f: 230, 0x0740: 230, 0x0741: 230, 0x0742: 220, 0x0743: 230,
+	0x0744: 220, 0x0745: 230, 0x0746: 220, 0x0747: 230, 0x0748: 220, 0x0749: 230, 0x074a: 230, 0x07eb: 230,
+	0x07ec: 230, 0x07ed: 230, 0x07ee: 230, 0x07ef: 230, 0x07f0: 230, 0x07f1: 230, 0x07f2: 220, 0x07f3: 230,
+	0x07fd: 220, 0x0816: 230, 0x0817: 230, 0x0818: 230, 0x0819: 230, 0x081b: 230, 0x081c: 230, 0x081d: 230,
+	0x081e: 230, 0x081f: 230, 0x0820: 230, 0x0821: 230, 0x0822: 230, 0x0823: 230, 0x0825: 230, 0x0826: 230,
+	0x0827: 230, 0x0829: 230, 0x082a: 230, 0x082b: 230, 0x082c: 230, 0x082d: 230, 0x0859: 220, 0x085a: 220,
+	0x085b: 220, 0x0898: 230, 0x0899: 220, 0x089a: 220, 0x089b: 220, 0x089c: 230, 0x089d: 230, 0x089e: 230,
+	0x089f: 230, 0x08ca: 230, 0x08cb: 230, 0x08cc: 230, 0x08cd: 230, 0x08ce: 230, 0x08cf: 220, 0x08d0: 220,
+	0x08d1: 220, 0x08d2: 220, 0x08d3: 220, 0x08d4: 230, 0x08d5: 230, 0x08d6: 230, 0x08d7: 230, 0x08d8: 230,
+	0x08d9: 230, 0x08da: 230, 0x08db: 230, 0x08dc: 230, 0x08dd: 230, 0x08de: 230, 0x08df: 230, 0x08e0: 230,
+	0x08e1: 230, 0x08e3: 220, 0x08e4: 230, 0x08e5: 230, 0x08e6: 220, 0x08e7: 230, 0x08e8: 230, 0x08e9: 220,
+	0x08ea: 230, 0x08eb: 230, 0x08ec: 230, 0x08ed: 220, 0x08ee: 220, 0x08ef: 220, 0x08f0: 27, 0x08f1: 28,
+	0x08f2: 29, 0x08f3: 230, 0x08f4: 230, 0x08f5: 230, 0x08f6: 220, 0x08f7: 230, 0x08f8: 230, 0x08f9: 220,
+	0x08fa: 220, 0x08fb: 230, 0x08fc: 230, 0x08fd: 230, 0x08fe: 230, 0x08ff: 230, 0x093c: 7, 0x094d: 9,
+	0x0951: 230, 0x0952: 220, 0x0953: 230, 0x0954: 230, 0x09bc: 7, 0x09cd: 9, 0x09fe: 230, 0x0a3c: 7,
+	0x0a4d: 9, 0x0abc: 7, 0x0acd: 9, 0x0b3c: 7, 0x0b4d: 9, 0x0bcd: 9, 0x0c3c: 7, 0x0c4d: 9,
+	0x0c55: 84, 0x0c56: 91, 0x0cbc: 7, 0x0ccd: 9, 0x0d3b: 9, 0x0d3c: 9, 0x0d4d: 9, 0x0dca: 9,
+	0x0e38: 103, 0x0e39: 103, 0x0e3a: 9, 0x0e48: 107, 0x0e49: 107, 0x0e4a: 107, 0x0e4b: 107, 0x0eb8: 118,
+	0x0eb9: 118, 0x0eba: 9, 0x0ec8: 122, 0x0ec9: 122, 0x0eca: 122, 0x0ecb: 122, 0x0f18: 220, 0x0f19: 220,
+	0x0f35: 220, 0x0f37: 220, 0x0f39: 216, 0x0f71: 129, 0x0f72: 130, 0x0f74: 132, 0x0f7a: 130, 0x0f7b: 130,
+	0x0f7c: 130, 0x0f7d: 130, 0x0f80: 130, 0x0f82: 230, 0x0f83: 230, 0x0f84: 9, 0x0f86: 230, 0x0f87: 230,
+	0x0fc6: 220, 0x1037: 7, 0x1039: 9, 0x103a: 9, 0x108d: 220, 0x135d: 230, 0x135e: 230, 0x135f: 230,
+	0x1714: 9, 0x1715: 9, 0x1734: 9, 0x17d2: 9, 0x17dd: 230, 0x18a9: 228, 0x1939: 222, 0x193a: 230,
+	0x193b: 220, 0x1a17: 230, 0x1a18: 220, 0x1a60: 9, 0x1a75: 230, 0x1a76: 230, 0x1a77: 230, 0x1a78: 230,
+	0x1a79: 230, 0x1a7a: 230, 0x1a7b: 230, 0x1a7c: 230, 0x1a7f: 220, 0x1ab0: 230, 0x1ab1: 230, 0x1ab2: 230,
+	0x1ab3: 230, 0x1ab4: 230, 0x1ab5: 220, 0x1ab6: 220, 0x1ab7: 220, 0x1ab8: 220, 0x1ab9: 220, 0x1aba: 220,
+	0x1abb: 230, 0x1abc: 230, 0x1abd: 220, 0x1abf: 220, 0x1ac0: 220, 0x1ac1: 230, 0x1ac2: 230, 0x1ac3: 220,
+	0x1ac4: 220, 0x1ac5: 230, 0x1ac6: 230, 0x1ac7: 230, 0x1ac8: 230, 0x1ac9: 230, 0x1aca: 220, 0x1acb: 230,
+	0x1acc: 230, 0x1acd: 230, 0x1ace: 230, 0x1b34: 7, 0x1b44: 9, 0x1b6b: 230, 0x1b6c: 220, 0x1b6d: 230,
+	0x1b6e: 230, 0x1b6f: 230, 0x1b70: 230, 0x1b71: 230, 0x1b72: 230, 0x1b73: 230, 0x1baa: 9, 0x1bab: 9,
+	0x1be6: 7, 0x1bf2: 9, 0x1bf3: 9, 0x1c37: 7, 0x1cd0: 230, 0x1cd1: 230, 0x1cd2: 230, 0x1cd4: 1,
+	0x1cd5: 220, 0x1cd6: 220, 0x1cd7: 220, 0x1cd8: 220, 0x1cd9: 220, 0x1cda: 230, 0x1cdb: 230, 0x1cdc: 220,
+	0x1cdd: 220, 0x1cde: 220, 0x1cdf: 220, 0x1ce0: 230, 0x1ce2: 1, 0x1ce3: 1, 0x1ce4: 1, 0x1ce5: 1,
+	0x1ce6: 1, 0x1ce7: 1, 0x1ce8: 1, 0x1ced: 220, 0x1cf4: 230, 0x1cf8: 230, 0x1cf9: 230, 0x1dc0: 230,
+	0x1dc1: 230, 0x1dc2: 220, 0x1dc3: 230, 0x1dc4: 230, 0x1dc5: 230, 0x1dc6: 230, 0x1dc7: 230, 0x1dc8: 230,
+	0x1dc9: 230, 0x1dca: 220, 0x1dcb: 230, 0x1dcc: 230, 0x1dcd: 234, 0x1dce: 214, 0x1dcf: 220, 0x1dd0: 202,
+	0x1dd1: 230, 0x1dd2: 230, 0x1dd3: 230, 0x1dd4: 230, 0x1dd5: 230, 0x1dd6: 230, 0x1dd7: 230, 0x1dd8: 230,
+	0x1dd9: 230, 0x1dda: 230, 0x1ddb: 230, 0x1ddc: 230, 0x1ddd: 230, 0x1dde: 230, 0x1ddf: 230, 0x1de0: 230,
+	0x1de1: 230, 0x1de2: 230, 0x1de3: 230, 0x1de4: 230, 0x1de5: 230, 0x1de6: 230, 0x1de7: 230, 0x1de8: 230,
+	0x1de9: 230, 0x1dea: 230, 0x1deb: 230, 0x1dec: 230, 0x1ded: 230, 0x1dee: 230, 0x1def: 230, 0x1df0: 230,
+	0x1df1: 230, 0x1df2: 230, 0x1df3: 230, 0x1df4: 230, 0x1df5: 230, 0x1df6: 232, 0x1df7: 228, 0x1df8: 228,
+	0x1df9: 220, 0x1dfa: 218, 0x1dfb: 230, 0x1dfc: 233, 0x1dfd: 220, 0x1dfe: 230, 0x1dff: 220, 0x20d0: 230,
+	0x20d1: 230, 0x20d2: 1, 0x20d3: 1, 0x20d4: 230, 0x20d5: 230, 0x20d6: 230, 0x20d7: 230, 0x20d8: 1,
+	0x20d9: 1, 0x20da: 1, 0x20db: 230, 0x20dc: 230, 0x20e1: 230, 0x20e5: 1, 0x20e6: 1, 0x20e7: 230,
+	0x20e8: 220, 0x20e9: 230, 0x20ea: 1, 0x20eb: 1, 0x20ec: 220, 0x20ed: 220, 0x20ee: 220, 0x20ef: 220,
+	0x20f0: 230, 0x2cef: 230, 0x2cf0: 230, 0x2cf1: 230, 0x2d7f: 9, 0x2de0: 230, 0x2de1: 230, 0x2de2: 230,
+	0x2de3: 230, 0x2de4: 230, 0x2de5: 230, 0x2de6: 230, 0x2de7: 230, 0x2de8: 230, 0x2de9: 230, 0x2dea: 230,
+	0x2deb: 230, 0x2dec: 230, 0x2ded: 230, 0x2dee: 230, 0x2def: 230, 0x2df0: 230, 0x2df1: 230, 0x2df2: 230,
+	0x2df3: 230, 0x2df4: 230, 0x2df5: 230, 0x2df6: 230, 0x2df7: 230, 0x2df8: 230, 0x2df9: 230, 0x2dfa: 230,
+	0x2dfb: 230, 0x2dfc: 230, 0x2dfd: 230, 0x2dfe: 230, 0x2dff: 230, 0x302a: 218, 0x302b: 228, 0x302c: 232,
+	0x302d: 222, 0x302e: 224, 0x302f: 224, 0x3099: 8, 0x309a: 8, 0xa66f: 230, 0xa674: 230, 0xa675: 230,
+	0xa676: 230, 0xa677: 230, 0xa678: 230, 0xa679: 230, 0xa67a: 230, 0xa67b: 230, 0xa67c: 230, 0xa67d: 230,
+	0xa69e: 230, 0xa69f: 230, 0xa6f0: 230, 0xa6f1: 230, 0xa806: 9, 0xa82c: 9, 0xa8c4: 9, 0xa8e0: 230,
+	0xa8e1: 230, 0xa8e2: 230, 0xa8e3: 230, 0xa8e4: 230, 0xa8e5: 230, 0xa8e6: 230, 0xa8e7: 230, 0xa8e8: 230,
+	0xa8e9: 230, 0xa8ea: 230, 0xa8eb: 230, 0xa8ec: 230, 0xa8ed: 230, 0xa8ee: 230, 0xa8ef: 230, 0xa8f0: 230,
+	0xa8f1: 230, 0xa92b: 220, 0xa92c: 220, 0xa92d: 220, 0xa953: 9, 0xa9b3: 7, 0xa9c0: 9, 0xaab0: 230,
+	0xaab2: 230, 0xaab3: 230, 0xaab4: 220, 0xaab7: 230, 0xaab8: 230, 0xaabe: 230, 0xaabf: 230, 0xaac1: 230,
+	0xaaf6: 9, 0xabed: 9, 0xfb1e: 26, 0xfe20: 230, 0xfe21: 230, 0xfe22: 230, 0xfe23: 230, 0xfe24: 230,
+	0xfe25: 230, 0xfe26: 230, 0xfe27: 220, 0xfe28: 220, 0xfe29: 220, 0xfe2a: 220, 0xfe2b: 220, 0xfe2c: 220,
+	0xfe2d: 220, 0xfe2e: 230, 0xfe2f: 230, 0x101fd: 220, 0x102e0: 220, 0x10376: 230, 0x10377: 230, 0x10378: 230,
+	0x10379: 230, 0x1037a: 230, 0x10a0d: 220, 0x10a0f: 230, 0x10a38: 230, 0x10a39: 1, 0x10a3a: 220, 0x10a3f: 9,
+	0x10ae5: 230, 0x10ae6: 220, 0x10d24: 230, 0x10d25: 230, 0x10d26: 230, 0x10d27: 230, 0x10eab: 230, 0x10eac: 230,
+	0x10efd: 220, 0x10efe: 220, 0x10eff: 220, 0x10f46: 220, 0x10f47: 220, 0x10f48: 230, 0x10f49: 230, 0x10f4a: 230,
+	0x10f4b: 220, 0x10f4c: 230, 0x10f4d: 220, 0x10f4e: 220, 0x10f4f: 220, 0x10f50: 220, 0x10f82: 230, 0x10f83: 220,
+	0x10f84: 230, 0x10f85: 220, 0x11046: 9, 0x11070: 9, 0x1107f: 9, 0x110b9: 9, 0x110ba: 7, 0x11100: 230,
+	0x11101: 230, 0x11102: 230, 0x11133: 9, 0x11134: 9, 0x11173: 7, 0x111c0: 9, 0x111ca: 7, 0x11235: 9,
+	0x11236: 7, 0x112e9: 7, 0x112ea: 9, 0x1133b: 7, 0x1133c: 7, 0x1134d: 9, 0x11366: 230, 0x11367: 230,
+	0x11368: 230, 0x11369: 230, 0x1136a: 230, 0x1136b: 230, 0x1136c: 230, 0x11370: 230, 0x11371: 230, 0x11372: 230,
+	0x11373: 230, 0x11374: 230, 0x11442: 9, 0x11446: 7, 0x1145e: 230, 0x114c2: 9, 0x114c3: 7, 0x115bf: 9,
+	0x115c0: 7, 0x1163f: 9, 0x116b6: 9, 0x116b7: 7, 0x1172b: 9, 0x11839: 9, 0x1183a: 7, 0x1193d: 9,
+	0x1193e: 9, 0x11943: 7, 0x119e0: 9, 0x11a34: 9, 0x11a47: 9, 0x11a99: 9, 0x11c3f: 9, 0x11d42: 7,
+	0x11d44: 9, 0x11d45: 9, 0x11d97: 9, 0x11f41: 9, 0x11f42: 9, 0x16af0: 1, 0x16af1: 1, 0x16af2: 1,
+	0x16af3: 1, 0x16af4: 1, 0x16b30: 230, 0x16b31: 230, 0x16b32: 230, 0x16b33: 230, 0x16b34: 230, 0x16b35: 230,
+	0x16b36: 230, 0x16ff0: 6, 0x16ff1: 6, 0x1bc9e: 1, 0x1d165: 216, 0x1d166: 216, 0x1d167: 1, 0x1d168: 1,
+	0x1d169: 1, 0x1d16d: 226, 0x1d16e: 216, 0x1d16f: 216, 0x1d170: 216, 0x1d171: 216, 0x1d172: 216, 0x1d17b: 220,
+	0x1d17c: 220, 0x1d17d: 220, 0x1d17e: 220, 0x1d17f: 220, 0x1d180: 220, 0x1d181: 220, 0x1d182: 220, 0x1d185: 230,
+	0x1d186: 230, 0x1d187: 230, 0x1d188: 230, 0x1d189: 230, 0x1d18a: 220, 0x1d18b: 220, 0x1d1aa: 230, 0x1d1ab: 230,
+	0x1d1ac: 230, 0x1d1ad: 230, 0x1d242: 230, 0x1d243: 230, 0x1d244: 230, 0x1e000: 230, 0x1e001: 230, 0x1e002: 230,
+	0x1e003: 230, 0x1e004: 230, 0x1e005: 230, 0x1e006: 230, 0x1e008: 230, 0x1e009: 230, 0x1e00a: 230, 0x1e00b: 230,
+	0x1e00c: 230, 0x1e00d: 230, 0x1e00e: 230, 0x1e00f: 230, 0x1e010: 230, 0x1e011: 230, 0x1e012: 230, 0x1e013: 230,
+	0x1e014: 230, 0x1e015: 230, 0x1e016: 230, 0x1e017: 230, 0x1e018: 230, 0x1e01b: 230, 0x1e01c: 230, 0x1e01d: 230,
+	0x1e01e: 230, 0x1e01f: 230, 0x1e020: 230, 0x1e021: 230, 0x1e023: 230, 0x1e024: 230, 0x1e026: 230, 0x1e027: 230,
+	0x1e028: 230, 0x1e029: 230, 0x1e02a: 230, 0x1e08f: 230, 0x1e130: 230, 0x1e131: 230, 0x1e132: 230, 0x1e133: 230,
+	0x1e134: 230, 0x1e135: 230, 0x1e136: 230, 0x1e2ae: 230, 0x1e2ec: 230, 0x1e2ed: 230, 0x1e2ee: 230, 0x1e2ef: 230,
+	0x1e4ec: 232, 0x1e4ed: 232, 0x1e4ee: 220, 0x1e4ef: 230, 0x1e8d0: 220, 0x1e8d1: 220, 0x1e8d2: 220, 0x1e8d3: 220,
+	0x1e8d4: 220, 0x1e8d5: 220, 0x1e8d6: 220, 0x1e944: 230, 0x1e945: 230, 0x1e946: 230, 0x1e947: 230, 0x1e948: 230,
+	0x1e949: 230, 0x1e94a: 7,
+}
@@ -0,0 +1,63 @@
+package os
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"unicode"
+)
+
+var unicodeData = flag.String("unicodedata", "", "UnicodeData.txt against which to check the generated decomposition tables")
+
+func TestDecompositionTables(t *testing.T) {
+	assigned := []*unicode.RangeTable{unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.Z}
+	for r, d := range decompositions {
+		if !unicode.In(r, assigned...) {
+			t.Errorf("%U: not assigned in Unicode %s", r, unicode.Version)
+		}
+		if s := r - hangulBase; s >= 0 && s < hangulCount {
+			t.Errorf("%U: unexpected Hangul syllable", r)
+		}
+		var last uint8
+		for _, s := range d {
+			if _, ok := decompositions[s]; ok {
+				t.Errorf("%U: decomposition %q is not full", r, d)
+			}
+			ccc := combiningClasses[s]
+			if ccc != 0 && ccc < last {
+				t.Errorf("%U: decomposition %q is not in canonical order", r, d)
+			}
+			last = ccc
+		}
+	}
+	for r := range combiningClasses {
+		if !unicode.In(r, unicode.M) {
+			t.Errorf("%U: combining class of a character that is not a mark", r)
+		}
+	}
+}
+
+func TestDecompositionGenerator(t *testing.T) {
+	if *unicodeData == "" {
+		t.Skip("pass -unicodedata with the path to UnicodeData.txt to check the generated tables")
+	}
+	output := filepath.Join(t.TempDir(), "decompose.go")
+	cmd := exec.Command("go", "run", "gen_decompose.go", "-data", *unicodeData, "-version", unicodeVersion, "-output", output)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("running generator: %s\n%s", err, out)
+	}
+	generated, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing, err := os.ReadFile("decompose.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, existing) {
+		t.Errorf("decompose.go does not match the tables generated from %s", *unicodeData)
+	}
+}
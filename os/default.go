@@ -0,0 +1,303 @@
+package os
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	defaultMu sync.RWMutex
+	defaultFS = New()
+)
+
+// Default returns the filesystem used by the package level functions.
+func Default() *FS {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultFS
+}
+
+// SetDefault replaces the filesystem used by the package level functions,
+// returning the previous one.
+func SetDefault(f *FS) *FS {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	old := defaultFS
+	defaultFS = f
+	return old
+}
+
+func AtExit(fn func(code int)) {
+	Default().AtExit(fn)
+}
+
+func Chdir(p string) error {
+	return Default().Chdir(p)
+}
+
+func Chmod(p string, mode os.FileMode) error {
+	return Default().Chmod(p, mode)
+}
+
+func Chown(p string, uid, gid int) error {
+	return Default().Chown(p, uid, gid)
+}
+
+func Chtimes(p string, atime, mtime time.Time) error {
+	return Default().Chtimes(p, atime, mtime)
+}
+
+func CheckLeaks(t TB) {
+	Default().CheckLeaks(t)
+}
+
+func ClearFaults() {
+	Default().ClearFaults()
+}
+
+func Clearenv() {
+	Default().Clearenv()
+}
+
+func Create(name string) (*File, error) {
+	return Default().Create(name)
+}
+
+func DirFS(dir string) fs.FS {
+	return Default().DirFS(dir)
+}
+
+func DumpTar(w io.Writer, dir string) error {
+	return Default().DumpTar(w, dir)
+}
+
+func DumpZip(w io.Writer, dir string) error {
+	return Default().DumpZip(w, dir)
+}
+
+func Environ() []string {
+	return Default().Environ()
+}
+
+func Exit(code int) {
+	Default().Exit(code)
+}
+
+func ExpandEnv(s string) string {
+	return Default().ExpandEnv(s)
+}
+
+func ExportDir(fakePath, hostPath string) error {
+	return Default().ExportDir(fakePath, hostPath)
+}
+
+func FindProcess(pid int) (*Process, error) {
+	return Default().FindProcess(pid)
+}
+
+func Getegid() int {
+	return Default().Getegid()
+}
+
+func Geteuid() int {
+	return Default().Geteuid()
+}
+
+func Getenv(key string) string {
+	return Default().Getenv(key)
+}
+
+func Getgid() int {
+	return Default().Getgid()
+}
+
+func Getgroups() ([]int, error) {
+	return Default().Getgroups()
+}
+
+func Getpid() int {
+	return Default().Getpid()
+}
+
+func Getppid() int {
+	return Default().Getppid()
+}
+
+func Getuid() int {
+	return Default().Getuid()
+}
+
+func Getwd() (string, error) {
+	return Default().Getwd()
+}
+
+func ImportDir(hostPath, fakePath string) error {
+	return Default().ImportDir(hostPath, fakePath)
+}
+
+func InjectFault(fault Fault) func() {
+	return Default().InjectFault(fault)
+}
+
+func Lchown(p string, uid, gid int) error {
+	return Default().Lchown(p, uid, gid)
+}
+
+func Link(oldname, newname string) error {
+	return Default().Link(oldname, newname)
+}
+
+func LoadTar(r io.Reader, dir string) error {
+	return Default().LoadTar(r, dir)
+}
+
+func LoadZip(r io.ReaderAt, size int64, dir string) error {
+	return Default().LoadZip(r, size, dir)
+}
+
+func LookupEnv(key string) (string, bool) {
+	return Default().LookupEnv(key)
+}
+
+func Lstat(name string) (os.FileInfo, error) {
+	return Default().Lstat(name)
+}
+
+func Mkdir(p string, fileMode os.FileMode) error {
+	return Default().Mkdir(p, fileMode)
+}
+
+func MkdirAll(p string, fileMode os.FileMode) error {
+	return Default().MkdirAll(p, fileMode)
+}
+
+func NewFile(fd uintptr, name string) *File {
+	return Default().NewFile(fd, name)
+}
+
+func Open(name string) (*File, error) {
+	return Default().Open(name)
+}
+
+func OpenFile(name string, flag int, perm os.FileMode) (*File, error) {
+	return Default().OpenFile(name, flag, perm)
+}
+
+func OpenFiles() []Descriptor {
+	return Default().OpenFiles()
+}
+
+func Pipe() (*File, *File, error) {
+	return Default().Pipe()
+}
+
+func Readlink(name string) (string, error) {
+	return Default().Readlink(name)
+}
+
+func Remove(name string) error {
+	return Default().Remove(name)
+}
+
+func RemoveAll(name string) error {
+	return Default().RemoveAll(name)
+}
+
+func Rename(oldpath, newpath string) error {
+	return Default().Rename(oldpath, newpath)
+}
+
+func Restore(s *Snapshot) {
+	Default().Restore(s)
+}
+
+func SetAtimeMode(mode AtimeMode) {
+	Default().SetAtimeMode(mode)
+}
+
+func SetClock(c Clock) {
+	Default().SetClock(c)
+}
+
+func SetCredentials(uid, gid int, groups ...int) {
+	Default().SetCredentials(uid, gid, groups...)
+}
+
+func SetEnviron(env []string) {
+	Default().SetEnviron(env)
+}
+
+func SetFileLimit(n int) {
+	Default().SetFileLimit(n)
+}
+
+func SetPersonality(p Personality) {
+	Default().SetPersonality(p)
+}
+
+func SetQuota(bytes, nodes int64) {
+	Default().SetQuota(bytes, nodes)
+}
+
+func Setenv(key, value string) error {
+	return Default().Setenv(key, value)
+}
+
+func StartJournal() *Journal {
+	return Default().StartJournal()
+}
+
+func StartProcess(name string, argv []string, attr *ProcAttr) (*Process, error) {
+	return Default().StartProcess(name, argv, attr)
+}
+
+func Stat(name string) (os.FileInfo, error) {
+	return Default().Stat(name)
+}
+
+func Statfs(p string, buf *Statfs_t) error {
+	return Default().Statfs(p, buf)
+}
+
+func StopJournal() *Journal {
+	return Default().StopJournal()
+}
+
+func Symlink(oldname, newname string) error {
+	return Default().Symlink(oldname, newname)
+}
+
+func TakeSnapshot() *Snapshot {
+	return Default().Snapshot()
+}
+
+func Truncate(name string, size int64) error {
+	return Default().Truncate(name, size)
+}
+
+func Unsetenv(key string) error {
+	return Default().Unsetenv(key)
+}
+
+func Umask(mask os.FileMode) os.FileMode {
+	return Default().Umask(mask)
+}
+
+func Watch(p string, recursive bool) (*Watcher, error) {
+	return Default().Watch(p, recursive)
+}
+
+func WriteBytes(p string, perm os.FileMode, data []byte) {
+	Default().WriteBytes(p, perm, data)
+}
+
+func WriteExecutable(p string, perm os.FileMode, fn Executable) {
+	Default().WriteExecutable(p, perm, fn)
+}
+
+func WriteString(p, data string) {
+	Default().WriteString(p, data)
+}
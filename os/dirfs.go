@@ -0,0 +1,116 @@
+package os
+
+import (
+	"io"
+	"io/fs"
+	"path"
+)
+
+// dirFS is an fs.FS over the directory of a filesystem.
+type dirFS struct {
+	fs  *FS
+	dir string
+}
+
+// DirFS returns an fs.FS for the tree of files rooted at the given directory.
+//
+// The returned value also implements fs.StatFS, fs.ReadDirFS, fs.ReadFileFS,
+// fs.GlobFS and fs.SubFS.
+func (f *FS) DirFS(dir string) fs.FS {
+	return &dirFS{f, f.abs(dir)}
+}
+
+// join returns the path in the filesystem of the given fs.FS path.
+func (d *dirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(d.dir, name), nil
+}
+
+// pathError converts an error from the filesystem to an *fs.PathError for the
+// given fs.FS path.
+func pathError(op, name string, err error) error {
+	if e, ok := err.(*PathError); ok {
+		err = e.Err
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (d *dirFS) Open(name string) (fs.File, error) {
+	p, err := d.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := d.fs.Open(p)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	return f, nil
+}
+
+func (d *dirFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := d.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := d.fs.Stat(p)
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+	return fi, nil
+}
+
+func (d *dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := d.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := d.fs.Open(p)
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+	defer f.Close()
+	entries, err := f.ReadDir(-1)
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+	return entries, nil
+}
+
+func (d *dirFS) ReadFile(name string) ([]byte, error) {
+	p, err := d.join("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := d.fs.Open(p)
+	if err != nil {
+		return nil, pathError("readfile", name, err)
+	}
+	defer f.Close()
+	if f.fi.IsDir() {
+		return nil, pathError("readfile", name, ErrIsDir)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, pathError("readfile", name, err)
+	}
+	return data, nil
+}
+
+func (d *dirFS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	// hide the Glob method so that fs.Glob uses ReadDir rather than
+	// calling back into this method
+	return fs.Glob(struct{ fs.ReadDirFS }{d}, pattern)
+}
+
+func (d *dirFS) Sub(dir string) (fs.FS, error) {
+	p, err := d.join("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	return &dirFS{d.fs, p}, nil
+}
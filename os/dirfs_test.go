@@ -0,0 +1,87 @@
+package os
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func newDirFSTest() fs.FS {
+	f := New()
+	f.WriteString("/data/a.txt", "hello")
+	f.WriteBytes("/data/sub/b.txt", 0644, []byte("world"))
+	f.Mkdir("/data/sub/empty", 0755)
+	f.Symlink("sub/b.txt", "/data/link")
+	f.WriteString("/outside.txt", "secret")
+	return f.DirFS("/data")
+}
+
+func TestDirFS(t *testing.T) {
+	if err := fstest.TestFS(newDirFSTest(), "a.txt", "sub/b.txt", "link"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirFSReadFile(t *testing.T) {
+	fsys := newDirFSTest()
+	for _, test := range [...]struct {
+		name, data string
+		err        error
+	}{
+		{"a.txt", "hello", nil},
+		{"sub/b.txt", "world", nil},
+		{"link", "world", nil},
+		{"missing", "", fs.ErrNotExist},
+		{"../outside.txt", "", fs.ErrInvalid},
+		{"/a.txt", "", fs.ErrInvalid},
+	} {
+		data, err := fs.ReadFile(fsys, test.name)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expecting error %v, got %v", test.name, test.err, err)
+		} else if string(data) != test.data {
+			t.Errorf("%s: expecting %q, got %q", test.name, test.data, data)
+		}
+	}
+}
+
+func TestDirFSGlob(t *testing.T) {
+	fsys := newDirFSTest()
+	for _, test := range [...]struct {
+		pattern string
+		matches []string
+	}{
+		{"*.txt", []string{"a.txt"}},
+		{"*/*.txt", []string{"sub/b.txt"}},
+		{"sub/*", []string{"sub/b.txt", "sub/empty"}},
+		{"none*", nil},
+	} {
+		matches, err := fs.Glob(fsys, test.pattern)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.pattern, err)
+		} else if !reflect.DeepEqual(matches, test.matches) {
+			t.Errorf("%s: expecting %v, got %v", test.pattern, test.matches, matches)
+		}
+	}
+	if _, err := fs.Glob(fsys, "["); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("expecting ErrBadPattern, got %v", err)
+	}
+}
+
+func TestDirFSSub(t *testing.T) {
+	sub, err := fs.Sub(newDirFSTest(), "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sub, "b.txt", "empty"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fs.ReadFile(sub, "b.txt"); err != nil || string(data) != "world" {
+		t.Fatalf("expecting %q, got %q (%v)", "world", data, err)
+	}
+	if _, err := fs.Sub(sub, "../sub"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("expecting ErrInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package os
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// environ is an in-memory set of environment variables.
+type environ struct {
+	mu   sync.RWMutex
+	vars map[string]string
+}
+
+func newEnviron(env []string) *environ {
+	e := &environ{vars: make(map[string]string, len(env))}
+	e.set(env)
+	return e
+}
+
+func (e *environ) set(env []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, kv := range env {
+		if p := strings.IndexByte(kv, '='); p > 0 {
+			e.vars[kv[:p]] = kv[p+1:]
+		}
+	}
+}
+
+func (e *environ) clear() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.vars = make(map[string]string)
+}
+
+func (e *environ) environ() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	env := make([]string, 0, len(e.vars))
+	for k, v := range e.vars {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
+
+func (e *environ) lookup(key string) (string, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	v, ok := e.vars[key]
+	return v, ok
+}
+
+func (e *environ) setenv(key, value string) error {
+	if key == "" || strings.ContainsAny(key, "=\x00") || strings.IndexByte(value, 0) >= 0 {
+		return ErrInvalid
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.vars[key] = value
+	return nil
+}
+
+func (e *environ) unsetenv(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.vars, key)
+}
+
+// SetEnviron adds the given "key=value" pairs to the environment of the
+// filesystem.
+func (f *FS) SetEnviron(env []string) {
+	f.env.set(env)
+}
+
+func (f *FS) Clearenv() {
+	f.env.clear()
+}
+
+func (f *FS) Environ() []string {
+	return f.env.environ()
+}
+
+func (f *FS) ExpandEnv(s string) string {
+	return Expand(s, f.Getenv)
+}
+
+func (f *FS) Getenv(key string) string {
+	v, _ := f.env.lookup(key)
+	return v
+}
+
+func (f *FS) LookupEnv(key string) (string, bool) {
+	return f.env.lookup(key)
+}
+
+func (f *FS) Setenv(key, value string) error {
+	return f.env.setenv(key, value)
+}
+
+func (f *FS) Unsetenv(key string) error {
+	f.env.unsetenv(key)
+	return nil
+}
+
+// Expand replaces $var or ${var} in the string based on the mapping function,
+// in the same manner as the standard library.
+func Expand(s string, mapping func(string) string) string {
+	return os.Expand(s, mapping)
+}
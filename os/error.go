@@ -15,6 +15,15 @@ var (
 	ErrClosed      = errors.New("file closed")
 	ErrIsDir       = errors.New("is directory")
 	ErrIsNotDir    = errors.New("is not directory")
+	ErrLoop        = errors.New("too many levels of symbolic links")
+	ErrExecFormat  = errors.New("exec format error")
+	ErrProcessDone = errors.New("process already finished")
+	ErrBrokenPipe  = errors.New("broken pipe")
+	ErrNoSpace     = errors.New("no space left on device")
+	ErrTooManyOpen = errors.New("too many open files")
+	ErrWouldBlock  = errors.New("resource temporarily unavailable")
+	ErrDeadlock    = errors.New("resource deadlock avoided")
+	ErrNoData      = errors.New("no such device or address")
 )
 
 type PathError struct {
@@ -26,6 +35,10 @@ func (p *PathError) Error() string {
 	return p.Op + " " + p.Path + ": " + p.Err.Error()
 }
 
+func (p *PathError) Unwrap() error {
+	return p.Err
+}
+
 type LinkError struct {
 	Op, Old, New string
 	Err          error
@@ -35,6 +48,10 @@ func (l *LinkError) Error() string {
 	return l.Op + " " + l.Old + " " + l.New + ": " + l.Err.Error()
 }
 
+func (l *LinkError) Unwrap() error {
+	return l.Err
+}
+
 func IsExist(err error) bool {
 	switch e := err.(type) {
 	case *PathError:
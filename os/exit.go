@@ -0,0 +1,48 @@
+package os
+
+import "strconv"
+
+// exitCode is the value that Exit panics with to unwind the calling goroutine.
+type exitCode int
+
+func (e exitCode) String() string {
+	return "exit status " + strconv.Itoa(int(e))
+}
+
+// AtExit registers a function to be called with the exit code when Exit is
+// called on the filesystem. Functions are called in the reverse order to that
+// in which they were registered.
+func (f *FS) AtExit(fn func(code int)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hooks = append(f.hooks, fn)
+}
+
+// Exit runs the functions registered with AtExit and then unwinds the calling
+// goroutine, which can be caught with CatchExit. When called from within an
+// Executable, the process exits with the given code.
+func (f *FS) Exit(code int) {
+	f.mu.RLock()
+	hooks := f.hooks
+	f.mu.RUnlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i](code)
+	}
+	panic(exitCode(code))
+}
+
+// CatchExit runs the given function, returning the code passed to Exit and
+// true if the function called Exit, or 0 and false if it returned normally.
+func CatchExit(fn func()) (code int, exited bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(exitCode)
+			if !ok {
+				panic(r)
+			}
+			code, exited = int(e), true
+		}
+	}()
+	fn()
+	return 0, false
+}
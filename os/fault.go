@@ -0,0 +1,202 @@
+package os
+
+import (
+	"path"
+	"sync"
+)
+
+// Op names a filesystem operation, for use with Fault.
+type Op string
+
+const (
+	OpAny      Op = ""
+	OpChmod    Op = "chmod"
+	OpChown    Op = "chown"
+	OpChtimes  Op = "chtimes"
+	OpClose    Op = "close"
+	OpLink     Op = "link"
+	OpMkdir    Op = "mkdir"
+	OpOpen     Op = "open"
+	OpRead     Op = "read"
+	OpReaddir  Op = "readdir"
+	OpReadlink Op = "readlink"
+	OpRemove   Op = "remove"
+	OpRename   Op = "rename"
+	OpStat     Op = "stat"
+	OpSymlink  Op = "symlink"
+	OpSync     Op = "sync"
+	OpTruncate Op = "truncate"
+	OpWrite    Op = "write"
+)
+
+// Fault describes an error to be returned from matching operations on a
+// filesystem.
+type Fault struct {
+	// Op is the operation to fail, with OpAny matching all operations.
+	Op Op
+	// Path is a glob, as used by path.Match, which is matched against the
+	// absolute path of the file being operated on. For Rename and Link the
+	// old path is matched. An empty Path matches all paths.
+	Path string
+	// Err is the error returned by matching operations.
+	Err error
+	// Bytes, when positive, allows that many bytes to be read or written by
+	// matching operations before they start to fail, with the operation
+	// that crosses the limit returning a short count along with Err.
+	Bytes int64
+	// Nth, when positive, fails only the Nth matching operation.
+	Nth int
+}
+
+type faultRule struct {
+	Fault
+	calls int
+	bytes int64
+}
+
+type faults struct {
+	mu    sync.Mutex
+	rules []*faultRule
+}
+
+// InjectFault adds a rule to the filesystem that causes matching operations
+// to fail. The returned function removes the rule.
+func (f *FS) InjectFault(fault Fault) func() {
+	r := &faultRule{Fault: fault}
+	f.faults.mu.Lock()
+	f.faults.rules = append(f.faults.rules, r)
+	f.faults.mu.Unlock()
+	return func() {
+		f.faults.mu.Lock()
+		defer f.faults.mu.Unlock()
+		for n, s := range f.faults.rules {
+			if s == r {
+				f.faults.rules = append(f.faults.rules[:n], f.faults.rules[n+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// ClearFaults removes all rules added with InjectFault.
+func (f *FS) ClearFaults() {
+	f.faults.mu.Lock()
+	defer f.faults.mu.Unlock()
+	f.faults.rules = nil
+}
+
+func (fs *faults) active() bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return len(fs.rules) > 0
+}
+
+// check returns the error of the first rule that fails the operation, along
+// with the number of bytes, of the n requested, that can be processed before
+// failing.
+func (fs *faults) check(op Op, p string, n int) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, r := range fs.rules {
+		if !r.matches(op, p) {
+			continue
+		}
+		if r.Bytes > 0 {
+			if remaining := r.Bytes - r.bytes; int64(n) > remaining {
+				return int(remaining), r.Err
+			}
+			continue
+		}
+		if r.Nth > 0 {
+			if r.calls++; r.calls != r.Nth {
+				continue
+			}
+		}
+		return 0, r.Err
+	}
+	return n, nil
+}
+
+// charge counts the n bytes processed by an operation on the given path
+// against the byte limits of the matching rules.
+func (fs *faults) charge(op Op, p string, n int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, r := range fs.rules {
+		if r.Bytes > 0 && r.matches(op, p) {
+			if r.bytes += int64(n); r.bytes > r.Bytes {
+				r.bytes = r.Bytes
+			}
+		}
+	}
+}
+
+func (r *faultRule) matches(op Op, p string) bool {
+	if r.Op != OpAny && r.Op != op {
+		return false
+	}
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, p); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// abs returns the absolute form of the given path.
+func (f *FS) abs(p string) string {
+	if len(p) > 0 && p[0] == '/' {
+		return path.Clean(p)
+	}
+	wd, _ := f.Getwd()
+	return path.Join(wd, p)
+}
+
+// fault returns any injected error for the operation on the given path.
+func (f *FS) fault(op Op, p string) error {
+	if !f.faults.active() {
+		return nil
+	}
+	_, err := f.faults.check(op, f.abs(p), 0)
+	return err
+}
+
+// fault returns any injected error for the operation on the file.
+func (f *File) fault(op Op) error {
+	if !f.fs.faults.active() {
+		return nil
+	}
+	_, err := f.fs.faults.check(op, f.path, 0)
+	return err
+}
+
+// io calls fn with b, first checking for injected errors for the operation
+// and limiting b to the number of bytes allowed before failing. The bytes
+// actually processed by fn are counted against the limits.
+func (f *File) io(op Op, b []byte, fn func([]byte) (int, error)) (int, error) {
+	if !f.fs.faults.active() {
+		return fn(b)
+	}
+	l, ferr := f.fs.faults.check(op, f.path, len(b))
+	if ferr == nil {
+		n, err := fn(b)
+		f.fs.faults.charge(op, f.path, n)
+		return n, err
+	}
+	var (
+		n   int
+		err error
+	)
+	if l > 0 {
+		n, err = fn(b[:l])
+		f.fs.faults.charge(op, f.path, n)
+		if err != nil || n < l {
+			return n, err
+		}
+	}
+	return n, &PathError{
+		string(op),
+		f.name,
+		ferr,
+	}
+}
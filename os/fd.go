@@ -0,0 +1,145 @@
+package os
+
+import "sort"
+
+// firstFd is the lowest descriptor handed out to files, with lower
+// descriptors being reserved for the standard streams.
+const firstFd = 3
+
+// SetFileLimit sets the maximum number of files that can be open at once on
+// the filesystem, after which opening a file fails with ErrTooManyOpen. A
+// limit of zero or less removes the limit.
+func (f *FS) SetFileLimit(n int) {
+	f.fdMu.Lock()
+	defer f.fdMu.Unlock()
+	f.fdLimit = n
+}
+
+// allocFd reserves the lowest unused descriptor.
+func (t *tree) allocFd() (uintptr, error) {
+	t.fdMu.Lock()
+	defer t.fdMu.Unlock()
+	if t.fdLimit > 0 && len(t.fds) >= t.fdLimit {
+		return 0, ErrTooManyOpen
+	}
+	fd := uintptr(firstFd)
+	for {
+		if _, ok := t.fds[fd]; !ok {
+			break
+		}
+		fd++
+	}
+	t.fds[fd] = nil
+	return fd, nil
+}
+
+// setFd assigns the file to its reserved descriptor.
+func (t *tree) setFd(f *File) {
+	t.fdMu.Lock()
+	defer t.fdMu.Unlock()
+	t.fds[f.fd] = f
+}
+
+func (t *tree) releaseFd(fd uintptr) {
+	t.fdMu.Lock()
+	defer t.fdMu.Unlock()
+	delete(t.fds, fd)
+}
+
+// isOpen returns true if the file holds its descriptor, and so has not been
+// closed.
+func (t *tree) isOpen(f *File) bool {
+	t.fdMu.Lock()
+	defer t.fdMu.Unlock()
+	return t.fds[f.fd] == f
+}
+
+// closeFd releases the descriptor held by the file, returning false if the
+// file has already been closed.
+func (t *tree) closeFd(f *File) bool {
+	t.fdMu.Lock()
+	defer t.fdMu.Unlock()
+	if t.fds[f.fd] != f {
+		return false
+	}
+	delete(t.fds, f.fd)
+	return true
+}
+
+// NewFile returns the open file with the given descriptor, or nil if there is
+// no such file. The name is ignored.
+func (f *FS) NewFile(fd uintptr, _ string) *File {
+	f.fdMu.Lock()
+	defer f.fdMu.Unlock()
+	return f.fds[fd]
+}
+
+// Descriptor describes an open file, as returned by OpenFiles.
+type Descriptor struct {
+	Fd     uintptr
+	Path   string
+	Flag   int
+	Offset int64
+}
+
+// OpenFiles returns the files open on the filesystem, ordered by descriptor.
+func (f *FS) OpenFiles() []Descriptor {
+	files := f.openFiles()
+	ds := make([]Descriptor, len(files))
+	type i interface {
+		offset() int64
+	}
+	for n, file := range files {
+		var offset int64
+		if o, ok := file.contents.(i); ok {
+			offset = o.offset()
+		}
+		ds[n] = Descriptor{
+			Fd:     file.fd,
+			Path:   file.path,
+			Flag:   file.flag,
+			Offset: offset,
+		}
+	}
+	return ds
+}
+
+func (t *tree) openFiles() []*File {
+	t.fdMu.Lock()
+	files := make([]*File, 0, len(t.fds))
+	for _, file := range t.fds {
+		if file != nil {
+			files = append(files, file)
+		}
+	}
+	t.fdMu.Unlock()
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].fd < files[j].fd
+	})
+	return files
+}
+
+// TB is the part of testing.TB used by CheckLeaks.
+type TB interface {
+	Helper()
+	Cleanup(func())
+	Errorf(format string, args ...interface{})
+}
+
+// CheckLeaks fails the test when it finishes if any of the files opened on the
+// filesystem after CheckLeaks was called are still open.
+func (f *FS) CheckLeaks(t TB) {
+	t.Helper()
+	before := make(map[*File]bool)
+	for _, file := range f.openFiles() {
+		before[file] = true
+	}
+	t.Cleanup(func() {
+		t.Helper()
+		for _, file := range f.openFiles() {
+			if !before[file] {
+				t.Errorf("file descriptor %d left open: %s", file.fd, file.path)
+			}
+		}
+	})
+}
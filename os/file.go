@@ -2,11 +2,10 @@ package os
 
 import (
 	"io"
+	"io/fs"
 	"os"
 	"path"
-	"unsafe"
-
-	"github.com/MJKWoolnough/memio"
+	"sync"
 )
 
 const (
@@ -21,24 +20,122 @@ const (
 )
 
 const (
-	SEEK_CUR = 0
-	SEEK_SET = 1
+	SEEK_SET = 0
+	SEEK_CUR = 1
 	SEEK_END = 2
+
+	// SEEK_DATA seeks to the next part of a file containing data.
+	SEEK_DATA = 3
+	// SEEK_HOLE seeks to the next hole in a file, or its end.
+	SEEK_HOLE = 4
 )
 
+// readWrite provides access to the contents of a bfile, holding the lock of
+// the bfile for each operation.
 type readWrite struct {
-	*memio.ReadWriteMem
+	f *bfile
+
+	// mu guards pos, and is taken before the lock of the bfile.
+	mu     sync.Mutex
+	pos    int64
+	append bool
+}
+
+func (r *readWrite) Read(p []byte) (int, error) {
+	defer r.f.accessed()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.f.mu.RLock()
+	defer r.f.mu.RUnlock()
+	if r.pos >= r.f.data.size {
+		return 0, io.EOF
+	}
+	n := r.f.data.readAt(p, r.pos)
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *readWrite) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalid
+	}
+	defer r.f.accessed()
+	r.f.mu.RLock()
+	defer r.f.mu.RUnlock()
+	n := r.f.data.readAt(p, off)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *readWrite) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.f.mu.RLock()
+	defer r.f.mu.RUnlock()
+	var err error
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.f.data.size
+	case SEEK_DATA:
+		offset, err = r.f.data.seekData(offset)
+	case SEEK_HOLE:
+		offset, err = r.f.data.seekHole(offset)
+	default:
+		err = ErrInvalid
+	}
+	if err == nil && offset < 0 {
+		err = ErrInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+// offset returns the current position of the handle.
+func (r *readWrite) offset() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pos
+}
+
+func (r *readWrite) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+	if r.append {
+		r.pos = r.f.data.size
+	}
+	n, err := r.f.writeAt(p, r.pos)
+	r.pos += int64(n)
+	return n, err
+}
+
+func (r *readWrite) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalid
+	}
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+	return r.f.writeAt(p, off)
 }
 
-func (readWrite) Readdir(_ int) ([]os.FileInfo, error) {
+func (*readWrite) Readdir(_ int) ([]os.FileInfo, error) {
 	return nil, ErrInvalid
 }
-func (readWrite) Readdirnames(_ int) ([]string, error) {
+func (*readWrite) Readdirnames(_ int) ([]string, error) {
 	return nil, ErrInvalid
 }
 
 type noWrite struct {
-	readWrite
+	*readWrite
 }
 
 func (noWrite) Write(_ []byte) (int, error) {
@@ -50,7 +147,7 @@ func (noWrite) WriteAt(_ []byte, _ int64) (int, error) {
 }
 
 type noRead struct {
-	readWrite
+	*readWrite
 }
 
 func (noRead) Read(_ []byte) (int, error) {
@@ -62,22 +159,25 @@ func (noRead) ReadAt(_ []byte, _ int64) (int, error) {
 }
 
 type directoryC struct {
+	mu       sync.Mutex
 	contents []os.FileInfo
 }
 
-func (d directoryC) Len() int {
+func (d *directoryC) Len() int {
 	return len(d.contents)
 }
 
-func (d directoryC) Less(i, j int) bool {
+func (d *directoryC) Less(i, j int) bool {
 	return d.contents[i].Name() < d.contents[j].Name()
 }
 
-func (d directoryC) Swap(i, j int) {
+func (d *directoryC) Swap(i, j int) {
 	d.contents[i], d.contents[j] = d.contents[j], d.contents[i]
 }
 
 func (d *directoryC) Readdir(n int) ([]os.FileInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	if len(d.contents) == 0 {
 		if n <= 0 {
 			return []os.FileInfo{}, nil
@@ -106,23 +206,23 @@ func (d *directoryC) Readdirnames(n int) ([]string, error) {
 	return names, nil
 }
 
-func (directoryC) Write(_ []byte) (int, error) {
+func (*directoryC) Write(_ []byte) (int, error) {
 	return 0, ErrInvalid
 }
 
-func (directoryC) WriteAt(_ []byte, _ int64) (int, error) {
+func (*directoryC) WriteAt(_ []byte, _ int64) (int, error) {
 	return 0, ErrInvalid
 }
 
-func (directoryC) Read(_ []byte) (int, error) {
+func (*directoryC) Read(_ []byte) (int, error) {
 	return 0, ErrInvalid
 }
 
-func (directoryC) ReadAt(_ []byte, _ int64) (int, error) {
+func (*directoryC) ReadAt(_ []byte, _ int64) (int, error) {
 	return 0, ErrInvalid
 }
 
-func (directoryC) Seek(_ int64, _ int) (int64, error) {
+func (*directoryC) Seek(_ int64, _ int) (int64, error) {
 	return 0, ErrInvalid
 }
 
@@ -139,25 +239,23 @@ type contents interface {
 type File struct {
 	fi   os.FileInfo
 	name string
+	path string
+	fd   uintptr
+	flag int
+	fs   *FS
 	contents
 }
 
-func Create(name string) (*File, error) {
-	return OpenFile(name, O_RDWR|O_CREATE|O_TRUNC, 0666)
-}
-
-func NewFile(fd uintptr, name string) *File {
-	if int(fd) < 0 {
-		return nil
-	}
-	return (*File)(unsafe.Pointer(fd))
+func (f *FS) Create(name string) (*File, error) {
+	return f.OpenFile(name, O_RDWR|O_CREATE|O_TRUNC, 0666)
 }
 
-func Open(name string) (*File, error) {
-	return OpenFile(name, O_RDONLY, 0)
+func (f *FS) Open(name string) (*File, error) {
+	return f.OpenFile(name, O_RDONLY, 0)
 }
 
-func OpenFile(name string, flag int, perm os.FileMode) (*File, error) {
+func (f *FS) OpenFile(name string, flag int, perm os.FileMode) (_ *File, err error) {
+	defer f.record(Entry{Op: OpOpen, Path: name, Flag: flag}, &err)
 	if name == "" {
 		return nil, &PathError{
 			"open",
@@ -165,18 +263,26 @@ func OpenFile(name string, flag int, perm os.FileMode) (*File, error) {
 			ErrInvalid,
 		}
 	}
-	dir, file := path.Split(path.Clean(name))
-	if file == "" {
-		file = "."
+	if err := f.fault(OpOpen, name); err != nil {
+		return nil, &PathError{
+			"open",
+			name,
+			err,
+		}
 	}
-	d, err := navigateTo(dir)
-	var f os.FileInfo
+	c := f.getCred()
+	d, file, err := f.resolve(name, true, c)
+	var (
+		fi      os.FileInfo
+		created bool
+	)
 	if err == nil {
-		f, err = d.get(file)
+		fi, err = d.get(file, c)
 		if flag&O_CREATE != 0 {
 			if IsNotExist(err) {
-				f, err = d.set(file, perm)
-			} else if flag&O_EXCL != 0 {
+				fi, err = d.create(file, perm&^f.getUmask(), c)
+				created = true
+			} else if err == nil && flag&O_EXCL != 0 {
 				err = ErrExist
 			}
 		}
@@ -188,40 +294,66 @@ func OpenFile(name string, flag int, perm os.FileMode) (*File, error) {
 			err,
 		}
 	}
-	if (!canWrite(f.Mode()) && flag&(O_RDWR|O_APPEND|O_TRUNC|O_WRONLY) != 0) || (!canRead(f.Mode()) && flag&(O_RDWR|O_RDWR) != 0) {
+	var want os.FileMode
+	if flag&(O_RDWR|O_APPEND|O_TRUNC|O_WRONLY) != 0 {
+		want |= permWrite
+	}
+	if flag&O_WRONLY == 0 {
+		want |= permRead
+	}
+	type a interface {
+		checkAccess(cred, os.FileMode) bool
+	}
+	if !created && !fi.(a).checkAccess(c, want) {
 		return nil, &PathError{
 			"open",
 			name,
 			ErrPermission,
 		}
 	}
+	fd, err := f.allocFd()
+	if err != nil {
+		return nil, &PathError{
+			"open",
+			name,
+			err,
+		}
+	}
 	type i interface {
 		getContents(int) (contents, error)
 	}
-	c, err := f.(i).getContents(flag)
+	cs, err := fi.(i).getContents(flag)
 	if err != nil {
+		f.releaseFd(fd)
 		return nil, &PathError{
 			"open",
 			name,
 			err,
 		}
 	}
-	return &File{
-		f,
+	fl := &File{
+		fi,
 		name,
-		c,
-	}, nil
-}
-
-func Pipe() (*File, *File, error) {
-	return nil, nil, ErrUnsupported
+		f.abs(name),
+		fd,
+		flag,
+		f,
+		cs,
+	}
+	f.setFd(fl)
+	if created {
+		f.notify(EventCreate, name)
+	} else if flag&O_TRUNC != 0 {
+		f.notify(EventWrite, name)
+	}
+	return fl, nil
 }
 
 func (f *File) valid() error {
 	if f == nil {
 		return ErrInvalid
 	}
-	if f.fi == nil {
+	if !f.fs.isOpen(f) {
 		return ErrClosed
 	}
 	return nil
@@ -231,7 +363,7 @@ func (f *File) validPath(op string) error {
 	if f == nil {
 		return ErrInvalid
 	}
-	if f.fi == nil {
+	if !f.fs.isOpen(f) {
 		return &PathError{
 			op,
 			f.name,
@@ -248,60 +380,98 @@ func (f *File) Chdir() error {
 	if !f.fi.IsDir() {
 		return ErrInvalid
 	}
-	cwdmu.Lock()
-	defer cwdmu.Unlock()
-	cwd = f.fi.(*directory)
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	f.fs.cwd = f.fi.(*directory)
 	return nil
 }
 
-func (f *File) Chmod(mode os.FileMode) error {
-	if f == nil {
-		return ErrInvalid
+func (f *File) Chmod(mode os.FileMode) (err error) {
+	defer f.record(OpChmod, nil, &err)
+	if err := f.validPath("chmod"); err != nil {
+		return err
 	}
-	if f.fi == nil {
+	if err := f.fault(OpChmod); err != nil {
 		return &PathError{
 			"chmod",
 			f.name,
-			ErrClosed,
+			err,
 		}
 	}
 	type i interface {
-		chmod(os.FileMode) error
+		chmod(os.FileMode, cred) error
 	}
-	if err := f.fi.(i).chmod(mode); err != nil {
+	if err := f.fi.(i).chmod(mode, f.fs.getCred()); err != nil {
 		return &PathError{
 			"chmod",
 			f.name,
 			err,
 		}
 	}
+	f.notify(EventChmod)
 	return nil
 }
 
-func (f *File) Chown(_, _ int) error {
+func (f *File) Chown(uid, gid int) (err error) {
+	defer f.record(OpChown, nil, &err)
 	if err := f.validPath("chown"); err != nil {
 		return err
 	}
-	return &PathError{
-		"chown",
-		f.name,
-		ErrUnsupported,
+	if err := f.fault(OpChown); err != nil {
+		return &PathError{
+			"chown",
+			f.name,
+			err,
+		}
+	}
+	type i interface {
+		chown(int, int, cred) error
 	}
+	if err := f.fi.(i).chown(uid, gid, f.fs.getCred()); err != nil {
+		return &PathError{
+			"chown",
+			f.name,
+			err,
+		}
+	}
+	f.notify(EventChmod)
+	return nil
 }
 
-func (f *File) Close() error {
+func (f *File) Close() (err error) {
+	defer f.record(OpClose, nil, &err)
 	if f == nil {
 		return ErrInvalid
 	}
-	f.fi = nil
+	if !f.fs.closeFd(f) {
+		return &PathError{
+			"close",
+			f.name,
+			ErrClosed,
+		}
+	}
+	type i interface {
+		close()
+	}
+	if c, ok := f.contents.(i); ok {
+		c.close()
+	}
+	f.fs.releaseLocks(f)
+	if err = f.fault(OpClose); err != nil {
+		return &PathError{
+			"close",
+			f.name,
+			err,
+		}
+	}
 	return nil
 }
 
 func (f *File) Fd() uintptr {
-	if f == nil {
+	if f == nil || !f.fs.isOpen(f) {
 		return ^(uintptr(0))
 	}
-	return uintptr(unsafe.Pointer(f))
+	return f.fd
 }
 
 func (f *File) Name() string {
@@ -311,31 +481,62 @@ func (f *File) Name() string {
 	return f.name
 }
 
-func (f *File) Read(b []byte) (int, error) {
+func (f *File) Read(b []byte) (n int, err error) {
+	defer f.record(OpRead, &n, &err)
 	if err := f.validPath("read"); err != nil {
 		return 0, err
 	}
-	return f.contents.Read(b)
+	return f.io(OpRead, b, f.contents.Read)
 }
 
-func (f *File) ReadAt(b []byte, off int64) (int, error) {
+func (f *File) ReadAt(b []byte, off int64) (n int, err error) {
+	defer f.record(OpRead, &n, &err)
 	if err := f.validPath("read"); err != nil {
 		return 0, err
 	}
-	return f.contents.ReadAt(b, off)
+	return f.io(OpRead, b, func(b []byte) (int, error) {
+		return f.contents.ReadAt(b, off)
+	})
 }
 
-func (f *File) Readdir(n int) ([]os.FileInfo, error) {
+func (f *File) Readdir(n int) (_ []os.FileInfo, err error) {
+	defer f.record(OpReaddir, nil, &err)
 	if err := f.valid(); err != nil {
 		return []os.FileInfo{}, err
 	}
+	if err := f.fault(OpReaddir); err != nil {
+		return []os.FileInfo{}, &PathError{
+			"readdir",
+			f.name,
+			err,
+		}
+	}
 	return f.contents.Readdir(n)
 }
 
-func (f *File) Readdirnames(n int) ([]string, error) {
+// ReadDir reads the contents of the directory and returns up to n entries,
+// as with Readdir.
+func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
+	fis, err := f.Readdir(n)
+	entries := make([]fs.DirEntry, len(fis))
+	for n, fi := range fis {
+		entries[n] = fs.FileInfoToDirEntry(fi)
+	}
+	return entries, err
+}
+
+func (f *File) Readdirnames(n int) (_ []string, err error) {
+	defer f.record(OpReaddir, nil, &err)
 	if err := f.valid(); err != nil {
 		return []string{}, err
 	}
+	if err := f.fault(OpReaddir); err != nil {
+		return []string{}, &PathError{
+			"readdirent",
+			f.name,
+			err,
+		}
+	}
 	return f.contents.Readdirnames(n)
 }
 
@@ -346,53 +547,90 @@ func (f *File) Seek(offset int64, whence int) (int64, error) {
 	return f.contents.Seek(offset, whence)
 }
 
-func (f *File) Stat() (os.FileInfo, error) {
+func (f *File) Stat() (_ os.FileInfo, err error) {
+	defer f.record(OpStat, nil, &err)
 	if err := f.validPath("stat"); err != nil {
 		return nil, err
 	}
-	return f.fi, nil
+	return fileInfo{path.Base(f.name), f.fi}, nil
 }
 
-func (f *File) Sync() error {
+func (f *File) Sync() (err error) {
+	defer f.record(OpSync, nil, &err)
 	if err := f.validPath("fsync"); err != nil {
 		return err
 	}
+	if err := f.fault(OpSync); err != nil {
+		return &PathError{
+			"fsync",
+			f.name,
+			err,
+		}
+	}
 	return nil
 }
 
-func (f *File) Truncate(size int64) error {
+func (f *File) Truncate(size int64) (err error) {
+	defer f.record(OpTruncate, nil, &err)
 	if err := f.validPath("truncate"); err != nil {
 		return err
 	}
+	if err := f.fault(OpTruncate); err != nil {
+		return &PathError{
+			"truncate",
+			f.name,
+			err,
+		}
+	}
 	if f.fi.IsDir() {
 		return ErrInvalid
 	}
-	fi, ok := f.fi.(*file)
+	fi, ok := f.fi.(*bfile)
 	if !ok {
 		return ErrInvalid
 	}
-	if size < int64(len(fi.Contents)) {
-		fi.Contents = fi.Contents[:size]
-	} else {
-		c := fi.Contents
-		fi.Contents = make([]byte, size)
-		copy(fi.Contents, c)
+	if f.flag&(O_WRONLY|O_RDWR) == 0 {
+		return &PathError{
+			"truncate",
+			f.name,
+			ErrInvalid,
+		}
+	}
+	if err := fi.truncate(size); err != nil {
+		return &PathError{
+			"truncate",
+			f.name,
+			err,
+		}
 	}
+	f.notify(EventWrite)
 	return nil
 }
 
-func (f *File) Write(b []byte) (int, error) {
+func (f *File) Write(b []byte) (n int, err error) {
+	defer f.record(OpWrite, &n, &err)
 	if err := f.validPath("write"); err != nil {
 		return 0, err
 	}
-	return f.contents.Write(b)
+	n, err = f.io(OpWrite, b, f.contents.Write)
+	if n > 0 {
+		f.notify(EventWrite)
+	}
+	return n, err
 }
 
-func (f *File) WriteAt(b []byte, off int64) (int, error) {
+func (f *File) WriteAt(b []byte, off int64) (n int, err error) {
+	defer f.record(OpWrite, &n, &err)
 	if err := f.validPath("write"); err != nil {
 		return 0, err
 	}
-	return f.contents.WriteAt(b, off)
+	n, err = f.io(OpWrite, b, func(b []byte) (int, error) {
+		return f.contents.WriteAt(b, off)
+	})
+	if n > 0 {
+		f.notify(EventWrite)
+	}
+	return n, err
 }
 
 func (f *File) WriteString(s string) (int, error) {
@@ -0,0 +1,28 @@
+package os
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTruncateNegative(t *testing.T) {
+	f := New()
+	f.WriteBytes("/file", 0644, []byte("data"))
+	fl, err := f.OpenFile("/file", O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.Close()
+	for n, err := range [...]error{
+		f.Truncate("/file", -1),
+		fl.Truncate(-1),
+	} {
+		var pe *PathError
+		if !errors.As(err, &pe) || pe.Op != "truncate" || pe.Err != ErrInvalid {
+			t.Errorf("test %d: expecting truncate PathError with ErrInvalid, got %v", n+1, err)
+		}
+	}
+	if fi, err := f.Stat("/file"); err != nil || fi.Size() != 4 {
+		t.Errorf("expecting size 4, got %v, %v", fi, err)
+	}
+}
@@ -1,11 +1,46 @@
 package os
 
-import "os"
+import (
+	"os"
+	"path"
+)
 
-func Lstat(name string) (os.FileInfo, error) {
-	return getFile(name)
+func (f *FS) Lstat(name string) (_ os.FileInfo, err error) {
+	defer f.record(Entry{Op: OpStat, Path: name}, &err)
+	if err := f.fault(OpStat, name); err != nil {
+		return nil, &PathError{
+			"lstat",
+			name,
+			err,
+		}
+	}
+	fi, err := f.getFile(name, false)
+	if err != nil {
+		return nil, &PathError{
+			"lstat",
+			name,
+			err,
+		}
+	}
+	return fileInfo{path.Base(name), fi}, nil
 }
 
-func Stat(name string) (os.FileInfo, error) {
-	return Lstat(name)
+func (f *FS) Stat(name string) (_ os.FileInfo, err error) {
+	defer f.record(Entry{Op: OpStat, Path: name}, &err)
+	if err := f.fault(OpStat, name); err != nil {
+		return nil, &PathError{
+			"stat",
+			name,
+			err,
+		}
+	}
+	fi, err := f.getFile(name, true)
+	if err != nil {
+		return nil, &PathError{
+			"stat",
+			name,
+			err,
+		}
+	}
+	return fileInfo{path.Base(name), fi}, nil
 }
@@ -2,14 +2,58 @@ package os
 
 import "os"
 
-func canExecute(f os.FileMode) bool {
-	return f&0111 > 0
+const (
+	permExecute os.FileMode = 1 << iota
+	permWrite
+	permRead
+)
+
+// access returns true if the credentials grant all of the requested
+// permissions on the node, following the owner/group/other rules, with root
+// able to read and write anything and execute anything with an execute bit
+// set.
+//
+// Must be called with the node lock held.
+func (n *node) access(c cred, want os.FileMode) bool {
+	if c.uid == 0 {
+		return want&permExecute == 0 || n.mode.IsDir() || n.mode&0111 != 0
+	}
+	perm := n.mode
+	if c.uid == n.uid {
+		perm >>= 6
+	} else if c.inGroup(n.gid) {
+		perm >>= 3
+	}
+	return perm&want == want
+}
+
+func (n *node) checkAccess(c cred, want os.FileMode) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.access(c, want)
 }
 
-func canWrite(f os.FileMode) bool {
-	return f&0222 > 0
+// canDelete returns true if the credentials allow the removal of the child
+// node from the directory, taking into account the sticky bit.
+//
+// Must be called with the directory lock held.
+func (n *node) canDelete(child os.FileInfo, c cred) bool {
+	if !n.access(c, permWrite|permExecute) {
+		return false
+	}
+	if n.mode&os.ModeSticky == 0 || c.uid == 0 || c.uid == n.uid {
+		return true
+	}
+	type i interface {
+		owner() int
+	}
+	return child.(i).owner() == c.uid
 }
 
-func canRead(f os.FileMode) bool {
-	return f&0444 > 0
+// isOwner returns true if the credentials allow the changing of the metadata
+// of the node.
+//
+// Must be called with the node lock held.
+func (n *node) isOwner(c cred) bool {
+	return c.uid == 0 || c.uid == n.uid
 }
@@ -5,93 +5,310 @@ import (
 	"sort"
 	"sync"
 	"time"
-
-	"github.com/MJKWoolnough/memio"
 )
 
-var (
-	root = &directory{
-		node{
-			os.ModeDir | 0777,
-			time.Now(),
-			"",
-			nil,
-		},
-		make(map[string]os.FileInfo),
-	}
-	cwdmu sync.Mutex
+// FS is an in-memory filesystem, with its own root, working directory and
+// umask.
+//
+// All of the methods on FS are safe for concurrent use.
+type FS struct {
+	*tree
+	mu    sync.RWMutex
 	cwd   *directory
-)
+	umask os.FileMode
+	cred  cred
+	env   *environ
+	pid   int
+	ppid  int
+	hooks []func(int)
+}
+
+// tree contains the state that is shared between a filesystem and the views of
+// it given to the processes started on it.
+type tree struct {
+	root *directory
+
+	// renameMu serialises renames, which need to hold the locks of two
+	// directories at once and may change the parent of a node.
+	renameMu sync.Mutex
 
-func init() {
+	procMu  sync.Mutex
+	lastPid int
+	procs   map[int]*Process
+
+	faults faults
+	quota  quota
+
+	lastIno uint64
+
+	fdMu    sync.Mutex
+	fds     map[uintptr]*File
+	fdLimit int
+
+	// optMu guards the options of the filesystem.
+	optMu       sync.RWMutex
+	atime       AtimeMode
+	clock       Clock
+	journal     *Journal
+	personality Personality
+
+	watchMu  sync.RWMutex
+	watchers map[*Watcher]struct{}
+
+	lockMu    sync.Mutex
+	lockCond  sync.Cond
+	locks     []*fileLock
+	lockWaits map[*File]*fileLock
+}
+
+// New creates a new filesystem containing only a /tmp directory, which is set
+// as the working directory.
+func New() *FS {
+	return NewWithClock(nil)
+}
+
+// NewWithClock creates a new filesystem, as with New, which uses the given
+// clock for all of its timestamps. A nil clock uses the system time.
+func NewWithClock(c Clock) *FS {
+	now := clockNow(c)
+	f := newFS(&directory{
+		node: node{
+			mode:    os.ModeDir | 0777,
+			nlink:   1,
+			atime:   now,
+			modTime: now,
+			ctime:   now,
+		},
+		Contents: make(map[string]os.FileInfo),
+	})
+	f.clock = c
+	f.Mkdir("/tmp", 0777)
+	f.Chdir("/tmp")
+	return f
+}
+
+func newFS(root *directory) *FS {
+	f := &FS{
+		tree: &tree{
+			root:  root,
+			procs: make(map[int]*Process),
+			fds:   make(map[uintptr]*File),
+
+			watchers:  make(map[*Watcher]struct{}),
+			lockWaits: make(map[*File]*fileLock),
+		},
+		cwd: root,
+		env: newEnviron(nil),
+	}
+	f.lockCond.L = &f.lockMu
 	root.parent = root
-	cwd = root
-	Mkdir("/tmp", 0777)
-	Chdir("/tmp")
+	root.tree = f.tree
+	root.ino = f.newIno()
+	f.quota.nodes = 1
+	f.pid = f.newPid()
+	return f
+}
+
+// Umask sets the file mode creation mask for the filesystem, returning the
+// previous mask.
+func (f *FS) Umask(mask os.FileMode) os.FileMode {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old := f.umask
+	f.umask = mask & os.ModePerm
+	return old
+}
+
+func (f *FS) getUmask() os.FileMode {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.umask
 }
 
-type dir interface {
-	file
-	get(string) (os.FileInfo, error)
-	set(string, os.FileInfo) error
-	removeFile(file)
+func (f *FS) getCwd() *directory {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.cwd
 }
 
-type file interface {
+// view creates a new FS sharing the tree of the filesystem, starting with the
+// same working directory, umask, credentials and a copy of the environment.
+func (f *FS) view() *FS {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return &FS{
+		tree:  f.tree,
+		cwd:   f.cwd,
+		umask: f.umask,
+		cred:  f.cred,
+		env:   newEnviron(f.env.environ()),
+		pid:   f.newPid(),
+		ppid:  f.pid,
+	}
 }
 
+// node contains the metadata common to all filesystem entries.
+//
+// The mutex guards the fields of the node as well as the contents of the
+// embedding type. The parent field is only changed while holding both the
+// mutex and the FS renameMu, so may be read while holding either.
 type node struct {
-	os.FileMode
+	mu      sync.RWMutex
+	tree    *tree
+	ino     uint64
+	mode    os.FileMode
+	atime   time.Time
 	modTime time.Time
+	ctime   time.Time
 	name    string
-	parent  dir
+	parent  *directory
+	nlink   uint64
+	uid     int
+	gid     int
 }
 
-func (n node) Name() string {
+func (n *node) Name() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
 	return n.name
 }
 
-func (n node) Mode() os.FileMode {
-	return n.FileMode
+func (n *node) Mode() os.FileMode {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.mode
 }
 
-func (n node) ModTime() time.Time {
+func (n *node) ModTime() time.Time {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
 	return n.modTime
 }
 
-func (n *node) chmod(fileMode os.FileMode) error {
-	n.FileMode = fileMode | (n.FileMode & os.ModeDir)
-	return nil
+func (n *node) getParent() *directory {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.parent
 }
 
-func (n *node) setModTime(m time.Time) {
-	n.modTime = m
+func (n *node) owner() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.uid
 }
 
-func (n *node) move(name string, d dir) error {
-	if n.parent == nil {
-		return ErrInvalid
-	}
-	if !canRead(n.parent.FileMode) {
+func (n *node) ownership() (int, int) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.uid, n.gid
+}
+
+func (n *node) chmod(fileMode os.FileMode, c cred) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.isOwner(c) {
 		return ErrPermission
 	}
-	f, err := n.parent.get(name)
-	if err != nil {
-		return err
+	n.mode = fileMode&^os.ModeDir | n.mode&os.ModeDir
+	n.changed()
+	return nil
+}
+
+func (n *node) chown(uid, gid int, c cred) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if uid == -1 {
+		uid = n.uid
 	}
-	if err := n.parent.removeFile(name); err != nil {
-		return err
+	if gid == -1 {
+		gid = n.gid
 	}
-	if err := d.set(name, f); err != nil {
-		return err
+	if c.uid != 0 && (uid != n.uid || gid != n.gid && (c.uid != n.uid || !c.inGroup(gid))) {
+		return ErrPermission
 	}
-	n.parent = d
+	n.uid = uid
+	n.gid = gid
+	n.changed()
 	return nil
 }
 
+func (n *node) setTimes(atime, mtime time.Time, c cred) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.isOwner(c) {
+		return ErrPermission
+	}
+	n.atime = atime
+	n.modTime = mtime
+	n.changed()
+	return nil
+}
+
+func (n *node) link() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nlink++
+	n.changed()
+}
+
+func (n *node) unlink() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.nlink--; n.nlink == 0 {
+		n.tree.quota.add(0, -1)
+	}
+	n.changed()
+}
+
+// setParent must be called with the node lock held
+func (n *node) setParent(name string, d *directory) {
+	n.name = name
+	n.parent = d
+	n.changed()
+}
+
+func (n *node) lock() {
+	n.mu.Lock()
+}
+
+func (n *node) unlock() {
+	n.mu.Unlock()
+}
+
+type linker interface {
+	link()
+	unlink()
+}
+
+// fileInfo is the os.FileInfo returned for a node found by name, as a node may
+// be linked under several names.
+type fileInfo struct {
+	name string
+	os.FileInfo
+}
+
+func (f fileInfo) Name() string {
+	return f.name
+}
+
+// nodeOf returns the underlying node of a FileInfo returned by the package.
+func nodeOf(fi os.FileInfo) os.FileInfo {
+	if f, ok := fi.(fileInfo); ok {
+		return f.FileInfo
+	}
+	return fi
+}
+
 type directory struct {
 	node
 	Contents map[string]os.FileInfo
+	lower    string
+	loaded   sync.Once
+	loadErr  error
+
+	// keys maps the matching keys of the names in Contents, under the
+	// personality of the filesystem, to those names.
+	keys map[string]string
 }
 
 func namecheck(name string) error {
@@ -101,57 +318,109 @@ func namecheck(name string) error {
 			return ErrInvalid
 		}
 	}
+	switch name {
+	case "", ".", "..":
+		return ErrInvalid
+	}
 	return nil
 }
 
-func (d *directory) create(name string, perm os.FileMode) (os.FileInfo, error) {
-	if !canWrite(d.FileMode) {
+func (d *directory) create(name string, perm os.FileMode, c cred) (os.FileInfo, error) {
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.access(c, permExecute) {
 		return nil, ErrPermission
 	}
-	if f, ok := d.Contents[name]; ok {
+	if _, f, ok := d.lookup(name); ok {
 		return f, nil
 	}
+	if !d.access(c, permWrite) {
+		return nil, ErrPermission
+	}
 	if err := namecheck(name); err != nil {
 		return nil, err
 	}
+	name = d.tree.storeName(name)
+	if err := d.tree.quota.reserve(0, 1); err != nil {
+		return nil, err
+	}
+	now := d.tree.now()
 	f := &bfile{
-		node{
-			perm &^ os.ModeDir,
-			time.Now(),
-			name,
-			d,
+		node: node{
+			tree:    d.tree,
+			ino:     d.tree.newIno(),
+			mode:    perm &^ os.ModeDir,
+			nlink:   1,
+			uid:     c.uid,
+			gid:     c.gid,
+			atime:   now,
+			modTime: now,
+			ctime:   now,
+			name:    name,
+			parent:  d,
 		},
-		make([]byte, 0),
 	}
-	d.Contents[name] = f
+	d.set(name, f)
+	d.modified()
 	return f, nil
 }
 
-func (d *directory) mkdir(name string, fileMode os.FileMode) (*directory, error) {
-	if !canWrite(d.FileMode) {
+func (d *directory) mkdir(name string, fileMode os.FileMode, c cred) (*directory, error) {
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.access(c, permWrite|permExecute) {
 		return nil, ErrPermission
 	}
-	if _, ok := d.Contents[name]; ok {
+	if _, _, ok := d.lookup(name); ok {
 		return nil, ErrExist
 	}
 	if err := namecheck(name); err != nil {
 		return nil, err
 	}
+	name = d.tree.storeName(name)
+	if err := d.tree.quota.reserve(0, 1); err != nil {
+		return nil, err
+	}
+	now := d.tree.now()
 	e := &directory{
-		node{
-			fileMode | os.ModeDir,
-			time.Now(),
-			name,
-			d,
+		node: node{
+			tree:    d.tree,
+			ino:     d.tree.newIno(),
+			mode:    fileMode | os.ModeDir,
+			nlink:   1,
+			uid:     c.uid,
+			gid:     c.gid,
+			atime:   now,
+			modTime: now,
+			ctime:   now,
+			name:    name,
+			parent:  d,
 		},
-		make(map[string]os.FileInfo),
+		Contents: make(map[string]os.FileInfo),
 	}
-	d.Contents[name] = e
+	d.set(name, e)
+	d.modified()
 	return e, nil
 }
 
-func (d *directory) get(name string) (os.FileInfo, error) {
-	if !canExecute(d.FileMode) {
+func (d *directory) get(name string, c cred) (os.FileInfo, error) {
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.getLocked(name, c)
+}
+
+// getLocked must be called with the directory lock held
+func (d *directory) getLocked(name string, c cred) (os.FileInfo, error) {
+	if !d.access(c, permExecute) {
 		return nil, ErrPermission
 	}
 	switch name {
@@ -160,85 +429,406 @@ func (d *directory) get(name string) (os.FileInfo, error) {
 	case "..":
 		return d.parent, nil
 	}
-	fi, ok := d.Contents[name]
+	_, fi, ok := d.lookup(name)
 	if !ok {
 		return nil, ErrNotExist
 	}
 	return fi, nil
 }
 
-func (d *directory) set(name string, f os.FileInfo) error {
-
+func (d *directory) remove(name string, all bool, c cred) error {
+	if err := d.load(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.removeLocked(name, all, c)
 }
 
-func (d *directory) remove(name string, all bool) error {
-	if !canWrite(d.FileMode) {
+// removeLocked must be called with the directory lock held
+func (d *directory) removeLocked(name string, all bool, c cred) error {
+	if !d.access(c, permExecute) {
 		return ErrPermission
 	}
-	fi, ok := d.Contents[name]
+	name, fi, ok := d.lookup(name)
 	if !ok {
 		return ErrNotExist
 	}
-	if fi.IsDir() {
-		dir := fi.(*directory)
+	if !d.canDelete(fi, c) {
+		return ErrPermission
+	}
+	if dir, ok := fi.(*directory); ok {
+		if err := dir.load(); err != nil {
+			return err
+		}
+		dir.mu.Lock()
+		defer dir.mu.Unlock()
 		if len(dir.Contents) > 0 {
-			if all {
-				for name := range dir.Contents {
-					err := dir.remove(name, true)
-					if err != nil {
-						return err
-					}
+			if !all {
+				return ErrNotEmpty
+			}
+			for name := range dir.Contents {
+				if err := dir.removeLocked(name, true, c); err != nil {
+					return err
 				}
-			} else {
+			}
+		}
+		dir.nlink--
+		d.tree.quota.add(0, -1)
+	} else {
+		fi.(linker).unlink()
+	}
+	d.unset(name)
+	d.modified()
+	return nil
+}
+
+// addLink adds the existing node fi to the directory under the given name.
+func (d *directory) addLink(name string, fi os.FileInfo, c cred) error {
+	if err := d.load(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.access(c, permWrite|permExecute) {
+		return ErrPermission
+	}
+	if _, _, ok := d.lookup(name); ok {
+		return ErrExist
+	}
+	if err := namecheck(name); err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return ErrPermission
+	}
+	name = d.tree.storeName(name)
+	fi.(linker).link()
+	d.set(name, fi)
+	d.modified()
+	return nil
+}
+
+// move must be called with the FS renameMu held
+func (d *directory) move(name string, e *directory, newName string, c cred) error {
+	if err := namecheck(name); err != nil {
+		return err
+	}
+	if err := namecheck(newName); err != nil {
+		return err
+	}
+	if err := d.load(); err != nil {
+		return err
+	}
+	if err := e.load(); err != nil {
+		return err
+	}
+	switch {
+	case d == e:
+		d.mu.Lock()
+		defer d.mu.Unlock()
+	case d.isWithin(e):
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		d.mu.Lock()
+		defer d.mu.Unlock()
+	default:
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	}
+	fi, err := d.getLocked(name, c)
+	if err != nil {
+		return err
+	}
+	name, _, _ = d.lookup(name)
+	if _, err = e.getLocked(newName, c); err != nil && !IsNotExist(err) {
+		return err
+	}
+	if !d.canDelete(fi, c) || !e.access(c, permWrite) {
+		return ErrPermission
+	}
+	newName = d.tree.storeName(newName)
+	stored, existing, ok := e.lookup(newName)
+	if ok && existing == fi {
+		// only a change to the case or normalisation of the name of an
+		// entry is a rename onto itself that has an effect
+		if d != e || stored != name || stored == newName {
+			return nil
+		}
+		existing = nil
+	} else if ok {
+		if !e.canDelete(existing, c) {
+			return ErrPermission
+		}
+		if ed, ok := existing.(*directory); ok {
+			if !fi.IsDir() {
+				return ErrIsDir
+			}
+			if err := ed.load(); err != nil {
+				return err
+			}
+			ed.mu.RLock()
+			l := len(ed.Contents)
+			ed.mu.RUnlock()
+			if l > 0 {
 				return ErrNotEmpty
 			}
+		} else if fi.IsDir() {
+			return ErrIsNotDir
+		}
+	}
+	type i interface {
+		lock()
+		unlock()
+		setParent(string, *directory)
+	}
+	n := fi.(i)
+	if sd, ok := fi.(*directory); ok {
+		if e.isWithin(sd) {
+			return ErrInvalid
+		}
+		if d != e && !sd.checkAccess(c, permWrite) {
+			return ErrPermission
 		}
 	}
-	delete(d.Contents, name)
+	d.unset(name)
+	e.unset(stored)
+	e.set(newName, fi)
+	d.modified()
+	e.modified()
+	if existing != nil {
+		existing.(linker).unlink()
+	}
+	n.lock()
+	defer n.unlock()
+	n.setParent(newName, e)
 	return nil
 }
 
+// isWithin returns true if the directory is e, or is a descendant of e.
+//
+// Must be called with the FS renameMu held.
+func (d *directory) isWithin(e *directory) bool {
+	for {
+		if d == e {
+			return true
+		}
+		if d == d.parent {
+			return false
+		}
+		d = d.parent
+	}
+}
+
+func (d *directory) IsDir() bool {
+	return true
+}
+
 func (d *directory) Size() int64 {
 	return 0
 }
 
 func (d *directory) Sys() interface{} {
-	return d.Contents
+	return d.stat(0, 0).sys()
 }
 
 func (d *directory) getContents(flag int) (contents, error) {
-	if flag&O_WRONLY != 0 {
+	if flag&(O_WRONLY|O_RDWR) != 0 {
 		return nil, ErrIsDir
 	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	d.mu.RLock()
 	list := make([]os.FileInfo, 0, len(d.Contents))
-	for _, fi := range d.Contents {
-		list = append(list, fi)
+	for name, fi := range d.Contents {
+		list = append(list, fileInfo{name, fi})
 	}
-	dir := &directoryC{list}
+	d.mu.RUnlock()
+	d.accessed()
+	dir := &directoryC{contents: list}
 	sort.Sort(dir)
 	return dir, nil
 }
 
+func (d *directory) symlink(name, target string, c cred) error {
+	if err := d.load(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.access(c, permWrite|permExecute) {
+		return ErrPermission
+	}
+	if _, _, ok := d.lookup(name); ok {
+		return ErrExist
+	}
+	if err := namecheck(name); err != nil {
+		return err
+	}
+	if err := d.tree.quota.reserve(0, 1); err != nil {
+		return err
+	}
+	name = d.tree.storeName(name)
+	now := d.tree.now()
+	d.set(name, &symlink{
+		node: node{
+			tree:    d.tree,
+			ino:     d.tree.newIno(),
+			mode:    os.ModeSymlink | 0777,
+			nlink:   1,
+			uid:     c.uid,
+			gid:     c.gid,
+			atime:   now,
+			modTime: now,
+			ctime:   now,
+			name:    name,
+			parent:  d,
+		},
+		target: target,
+	})
+	d.modified()
+	return nil
+}
+
 type bfile struct {
 	node
-	Contents []byte
+	data    chunks
+	program Executable
+	lower   string
+	loaded  sync.Once
+	loadErr error
+}
+
+func (f *bfile) IsDir() bool {
+	return false
 }
 
 func (f *bfile) Size() int64 {
-	return int64(len(f.Contents))
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.data.size
 }
 
 func (f *bfile) Sys() interface{} {
-	return &f.Contents
+	f.mu.RLock()
+	size, allocated := f.data.size, f.allocated()
+	f.mu.RUnlock()
+	return f.stat(size, allocated).sys()
+}
+
+func (f *bfile) truncate(size int64) error {
+	if size < 0 {
+		return ErrInvalid
+	}
+	if err := f.load(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resize(size)
+	f.modified()
+	return nil
+}
+
+func (f *bfile) unlink() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.nlink--; f.nlink == 0 {
+		f.tree.quota.add(-f.allocated(), -1)
+	}
+	f.changed()
+}
+
+// allocated returns the number of bytes of storage used by the file, which,
+// for a file not yet loaded from the host, is assumed to have no holes.
+//
+// Must be called with the file lock held.
+func (f *bfile) allocated() int64 {
+	if f.lower != "" {
+		return allocatedSize(f.data.size)
+	}
+	return f.data.allocated()
+}
+
+// resize changes the size of the file, returning any storage freed to the
+// quota of the filesystem. Growing a file allocates no storage.
+//
+// Must be called with the file lock held.
+func (f *bfile) resize(size int64) {
+	before := f.data.allocated()
+	f.data.truncate(size)
+	if f.nlink > 0 {
+		f.tree.quota.add(f.data.allocated()-before, 0)
+	}
+}
+
+// grow accounts for the storage needed to write p at the given offset,
+// returning the part of p that can be written without exceeding the quota of
+// the filesystem, along with ErrNoSpace if that is not all of p.
+//
+// Must be called with the file lock held.
+func (f *bfile) grow(off int64, p []byte) ([]byte, error) {
+	if len(p) == 0 || f.nlink == 0 {
+		return p, nil
+	}
+	first, last := off/chunkSize, (off+int64(len(p))-1)/chunkSize
+	var want int64
+	for i := first; i <= last; i++ {
+		if !f.data.has(i) {
+			want += chunkSize
+		}
+	}
+	if want == 0 {
+		return p, nil
+	}
+	got := f.tree.quota.reserveUpTo(want)
+	if got == want {
+		return p, nil
+	}
+	f.tree.quota.add(-(got % chunkSize), 0)
+	for i := first; ; i++ {
+		if f.data.has(i) {
+			continue
+		}
+		if got < chunkSize {
+			if keep := i*chunkSize - off; keep > 0 {
+				return p[:keep], ErrNoSpace
+			}
+			return p[:0], ErrNoSpace
+		}
+		got -= chunkSize
+	}
+}
+
+// writeAt writes as much of p at the given offset as the quota of the
+// filesystem allows.
+//
+// Must be called with the file lock held.
+func (f *bfile) writeAt(p []byte, off int64) (int, error) {
+	p, err := f.grow(off, p)
+	if len(p) > 0 {
+		f.data.writeAt(p, off)
+		f.modified()
+	}
+	return len(p), err
 }
 
 func (f *bfile) getContents(flag int) (contents, error) {
-	rw := readWrite{memio.OpenMem(&f.Contents)}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if flag&O_TRUNC != 0 {
-		f.Contents = f.Contents[:0]
+		f.resize(0)
+		f.modified()
 	}
-	if flag&O_APPEND != 0 {
-		rw.Seek(0, 2)
+	rw := &readWrite{
+		f:      f,
+		append: flag&O_APPEND != 0,
 	}
 	if flag&O_RDWR != 0 {
 		return rw, nil
@@ -248,3 +838,28 @@ func (f *bfile) getContents(flag int) (contents, error) {
 	}
 	return noWrite{rw}, nil
 }
+
+type symlink struct {
+	node
+	target string
+}
+
+func (l *symlink) IsDir() bool {
+	return false
+}
+
+func (l *symlink) Size() int64 {
+	return int64(len(l.target))
+}
+
+func (l *symlink) Sys() interface{} {
+	return l.stat(l.Size(), 0).sys()
+}
+
+func (l *symlink) chmod(_ os.FileMode, _ cred) error {
+	return nil
+}
+
+func (l *symlink) getContents(_ int) (contents, error) {
+	return nil, ErrInvalid
+}
@@ -7,50 +7,93 @@ import (
 	"time"
 )
 
-func navigateTo(p string) (dir, error) {
+// maxSymlinks is the number of symbolic links that will be followed during a
+// single lookup before returning ErrLoop.
+const maxSymlinks = 40
+
+func (f *FS) navigateTo(p string, c cred) (*directory, error) {
+	hops := 0
+	return f.walk(f.getCwd(), p, c, &hops)
+}
+
+// walk follows the path p from the directory d, following any symbolic links,
+// and returns the directory it refers to.
+func (f *FS) walk(d *directory, p string, c cred, hops *int) (*directory, error) {
 	if len(p) == 0 {
-		return cwd, nil
+		return d, nil
 	}
-	d := dir(cwd)
 	if p[0] == '/' {
-		d = root
+		d = f.root
 		p = p[1:]
 	}
 	for _, dir := range strings.Split(p, "/") {
 		switch dir {
 		case "", ".":
-		case "..":
-			if !canRead(d.parent.FileMode) {
-				return nil, ErrPermission
-			}
-			d = d.get("..")
 		default:
-			fi, err := d.get(dir)
+			fi, err := d.get(dir, c)
 			if err != nil {
 				return nil, err
 			}
-			if !fi.IsDir() {
+			switch fi := fi.(type) {
+			case *directory:
+				d = fi
+			case *symlink:
+				if *hops++; *hops > maxSymlinks {
+					return nil, ErrLoop
+				}
+				if d, err = f.walk(d, fi.target, c, hops); err != nil {
+					return nil, err
+				}
+			default:
 				return nil, ErrIsNotDir
 			}
-			d = fi.(dir)
 		}
 	}
 	return d, nil
 }
 
-func getFile(p string) (os.FileInfo, error) {
-	dir, file := path.Split(path.Clean(p))
-	d, err := navigateTo(dir)
+// resolve returns the directory containing the last element of the path, and
+// the name of that element within the directory. If follow is true, and the
+// last element is a symbolic link, the link is followed.
+func (f *FS) resolve(p string, follow bool, c cred) (*directory, string, error) {
+	hops := 0
+	dir, name := path.Split(path.Clean(p))
+	d, err := f.walk(f.getCwd(), dir, c, &hops)
+	for err == nil {
+		if name == "" {
+			name = "."
+		}
+		if !follow {
+			break
+		}
+		fi, gerr := d.get(name, c)
+		if gerr != nil {
+			break
+		}
+		l, ok := fi.(*symlink)
+		if !ok {
+			break
+		}
+		if hops++; hops > maxSymlinks {
+			return nil, "", ErrLoop
+		}
+		dir, name = path.Split(path.Clean(l.target))
+		d, err = f.walk(d, dir, c, &hops)
+	}
+	return d, name, err
+}
+
+func (f *FS) getFile(p string, follow bool) (os.FileInfo, error) {
+	c := f.getCred()
+	d, name, err := f.resolve(p, follow, c)
 	if err != nil {
 		return nil, err
 	}
-	return d.get(file)
+	return d.get(name, c)
 }
 
-func Chdir(p string) error {
-	cwdmu.Lock()
-	defer cwdmu.Unlock()
-	c, err := navigateTo(path.Clean(p))
+func (f *FS) Chdir(p string) error {
+	d, err := f.navigateTo(path.Clean(p), f.getCred())
 	if err != nil {
 		return &PathError{
 			"chdir",
@@ -58,17 +101,27 @@ func Chdir(p string) error {
 			err,
 		}
 	}
-	cwd = c
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cwd = d
 	return nil
 }
 
-func Chmod(p string, mode os.FileMode) error {
-	f, err := getFile(p)
+func (f *FS) Chmod(p string, mode os.FileMode) (err error) {
+	defer f.record(Entry{Op: OpChmod, Path: p}, &err)
+	if err := f.fault(OpChmod, p); err != nil {
+		return &PathError{
+			"chmod",
+			p,
+			err,
+		}
+	}
+	fi, err := f.getFile(p, true)
 	if err == nil {
 		type i interface {
-			chmod(os.FileMode) error
+			chmod(os.FileMode, cred) error
 		}
-		err = f.(i).chmod(mode)
+		err = fi.(i).chmod(mode, f.getCred())
 	}
 	if err != nil {
 		return &PathError{
@@ -77,94 +130,79 @@ func Chmod(p string, mode os.FileMode) error {
 			err,
 		}
 	}
+	f.notify(EventChmod, p)
 	return nil
 }
 
-func Chown(p string, _, _ int) error {
-	return &PathError{
-		"chown",
-		p,
-		ErrUnsupported,
-	}
+func (f *FS) Chown(p string, uid, gid int) error {
+	return f.chown("chown", p, uid, gid, true)
 }
 
-func Chtimes(p string, _, mtime time.Time) error {
-	f, err := getFile(p)
-	if err != nil {
+func (f *FS) chown(op, p string, uid, gid int, follow bool) (err error) {
+	defer f.record(Entry{Op: OpChown, Path: p}, &err)
+	if err := f.fault(OpChown, p); err != nil {
 		return &PathError{
-			"chtimes",
+			op,
 			p,
 			err,
 		}
 	}
-	type i interface {
-		setModTime(time.Time)
+	fi, err := f.getFile(p, follow)
+	if err == nil {
+		type i interface {
+			chown(int, int, cred) error
+		}
+		err = fi.(i).chown(uid, gid, f.getCred())
 	}
-	f.(i).setModTime(mtime)
+	if err != nil {
+		return &PathError{
+			op,
+			p,
+			err,
+		}
+	}
+	f.notify(EventChmod, p)
 	return nil
 }
 
-func Clearenv() {
-
-}
-
-func Environ() []string {
-	return []string{}
-}
-
-func Exit(_ int) {
-
-}
-
-func Expand(s string, mapping func(string) string) string {
-	return s
-}
-
-func ExpandEnv(s string) string {
-	return s
-}
-
-func Getegid() int {
-	return 0
-}
-
-func Getenv(_ string) string {
-	return ""
-}
-
-func Geteuid() int {
-	return 0
-}
-
-func Getgid() int {
-	return 0
-}
-
-func Getgroups() ([]int, error) {
-	return []int{}, nil
+func (f *FS) Chtimes(p string, atime, mtime time.Time) (err error) {
+	defer f.record(Entry{Op: OpChtimes, Path: p}, &err)
+	if err := f.fault(OpChtimes, p); err != nil {
+		return &PathError{
+			"chtimes",
+			p,
+			err,
+		}
+	}
+	fi, err := f.getFile(p, true)
+	if err == nil {
+		type i interface {
+			setTimes(time.Time, time.Time, cred) error
+		}
+		err = fi.(i).setTimes(atime, mtime, f.getCred())
+	}
+	if err != nil {
+		return &PathError{
+			"chtimes",
+			p,
+			err,
+		}
+	}
+	f.notify(EventChmod, p)
+	return nil
 }
 
 func Getpagesize() int {
 	return 0
 }
 
-func Getpid() int {
-	return 0
-}
-
-func Getppid() int {
-	return 0
-}
-
-func Getuid() int {
-	return 0
-}
-
-func Getwd() (string, error) {
-	if cwd == root {
+func (f *FS) Getwd() (string, error) {
+	d := f.getCwd()
+	if d == f.root {
 		return "/", nil
 	}
-	d := cwd
+	f.renameMu.Lock()
+	defer f.renameMu.Unlock()
 	names := make([]string, 1, 32)
 	names[0] = d.Name()
 	for d != d.parent {
@@ -186,28 +224,57 @@ func IsPathSeparator(c uint8) bool {
 	return c == '/'
 }
 
-func Lchown(p string, _, _ int) error {
-	return &PathError{
-		"lchown",
-		p,
-		ErrUnsupported,
-	}
+func (f *FS) Lchown(p string, uid, gid int) error {
+	return f.chown("lchown", p, uid, gid, false)
 }
 
-func Link(oldname, newname string) error {
-	return &LinkError{
-		"link",
-		oldname,
-		newname,
-		ErrUnsupported,
+func (f *FS) Link(oldname, newname string) (err error) {
+	defer f.record(Entry{Op: OpLink, Path: oldname, NewPath: newname}, &err)
+	if err := f.fault(OpLink, oldname); err != nil {
+		return &LinkError{
+			"link",
+			oldname,
+			newname,
+			err,
+		}
+	}
+	c := f.getCred()
+	fi, err := f.getFile(oldname, false)
+	if err == nil {
+		var (
+			d    *directory
+			name string
+		)
+		if d, name, err = f.resolve(newname, false, c); err == nil {
+			err = d.addLink(name, fi, c)
+		}
 	}
+	if err != nil {
+		return &LinkError{
+			"link",
+			oldname,
+			newname,
+			err,
+		}
+	}
+	f.notify(EventCreate, newname)
+	return nil
 }
 
-func Mkdir(p string, fileMode os.FileMode) error {
+func (f *FS) Mkdir(p string, fileMode os.FileMode) (err error) {
+	defer f.record(Entry{Op: OpMkdir, Path: p}, &err)
+	if err := f.fault(OpMkdir, p); err != nil {
+		return &PathError{
+			"mkdir",
+			p,
+			err,
+		}
+	}
+	c := f.getCred()
 	dir, toMake := path.Split(path.Clean(p))
-	d, err := navigateTo(dir)
+	d, err := f.navigateTo(dir, c)
 	if err == nil {
-		_, err = d.mkdir(toMake, fileMode)
+		_, err = d.mkdir(toMake, fileMode&^f.getUmask(), c)
 	}
 	if err != nil {
 		return &PathError{
@@ -216,22 +283,46 @@ func Mkdir(p string, fileMode os.FileMode) error {
 			err,
 		}
 	}
+	f.notify(EventCreate, p)
 	return nil
 }
 
-func MkdirAll(p string, fileMode os.FileMode) error {
-	p = path.Clean(p)
-	d := cwd
-	if p[0] == '/' {
-		d = root
-		p = p[1:]
+func (f *FS) MkdirAll(p string, fileMode os.FileMode) (err error) {
+	defer f.record(Entry{Op: OpMkdir, Path: p}, &err)
+	if err := f.fault(OpMkdir, p); err != nil {
+		return &PathError{
+			"mkdir",
+			p,
+			err,
+		}
 	}
-	var err error
-	for _, dir := range strings.Split(p, "/") {
-		d, err = d.mkdir(dir, fileMode)
-		if IsPermission(err) {
+	d := f.getCwd()
+	made := ""
+	if len(p) > 0 && p[0] == '/' {
+		d = f.root
+		made = "/"
+	}
+	fileMode &^= f.getUmask()
+	c := f.getCred()
+	hops := 0
+	for _, dir := range strings.Split(path.Clean(p), "/") {
+		switch dir {
+		case "", ".":
+			continue
+		}
+		made = path.Join(made, dir)
+		_, err := d.get(dir, c)
+		if IsNotExist(err) {
+			if _, err = d.mkdir(dir, fileMode, c); err == nil {
+				f.notify(EventCreate, made)
+			}
+		}
+		if err == nil {
+			d, err = f.walk(d, dir, c, &hops)
+		}
+		if err != nil {
 			return &PathError{
-				"mkdirall",
+				"mkdir",
 				p,
 				err,
 			}
@@ -244,19 +335,44 @@ func NewSyscallError(_, string, _ error) error {
 	return nil
 }
 
-func Readlink(name string) (string, error) {
+func (f *FS) Readlink(name string) (_ string, err error) {
+	defer f.record(Entry{Op: OpReadlink, Path: name}, &err)
+	if err := f.fault(OpReadlink, name); err != nil {
+		return "", &PathError{
+			"readlink",
+			name,
+			err,
+		}
+	}
+	fi, err := f.getFile(name, false)
+	if err == nil {
+		if l, ok := fi.(*symlink); ok {
+			l.accessed()
+			return l.target, nil
+		}
+		err = ErrInvalid
+	}
 	return "", &PathError{
 		"readlink",
 		name,
-		ErrUnsupported,
+		err,
 	}
 }
 
-func Remove(name string) error {
+func (f *FS) Remove(name string) (err error) {
+	defer f.record(Entry{Op: OpRemove, Path: name}, &err)
+	if err := f.fault(OpRemove, name); err != nil {
+		return &PathError{
+			"remove",
+			name,
+			err,
+		}
+	}
+	c := f.getCred()
 	dir, file := path.Split(path.Clean(name))
-	d, err := navigateTo(dir)
+	d, err := f.navigateTo(dir, c)
 	if err == nil {
-		err = d.remove(file, false)
+		err = d.remove(file, false, c)
 	}
 	if err != nil {
 		return &PathError{
@@ -265,14 +381,24 @@ func Remove(name string) error {
 			err,
 		}
 	}
+	f.notify(EventRemove, name)
 	return nil
 }
 
-func RemoveAll(name string) error {
+func (f *FS) RemoveAll(name string) (err error) {
+	defer f.record(Entry{Op: OpRemove, Path: name}, &err)
+	if err := f.fault(OpRemove, name); err != nil {
+		return &PathError{
+			"remove",
+			name,
+			err,
+		}
+	}
+	c := f.getCred()
 	dir, file := path.Split(path.Clean(name))
-	d, err := navigateTo(dir)
+	d, err := f.navigateTo(dir, c)
 	if err == nil {
-		err = d.remove(file, true)
+		err = d.remove(file, true, c)
 	}
 	if err != nil && !IsNotExist(err) {
 		return &PathError{
@@ -281,22 +407,32 @@ func RemoveAll(name string) error {
 			err,
 		}
 	}
+	if err == nil {
+		f.notify(EventRemove, name)
+	}
 	return nil
 }
 
-func Rename(oldpath, newpath string) error {
+func (f *FS) Rename(oldpath, newpath string) (err error) {
+	defer f.record(Entry{Op: OpRename, Path: oldpath, NewPath: newpath}, &err)
+	if err := f.fault(OpRename, oldpath); err != nil {
+		return &LinkError{
+			"rename",
+			oldpath,
+			newpath,
+			err,
+		}
+	}
+	c := f.getCred()
 	olddir, oldfile := path.Split(path.Clean(oldpath))
 	newdir, newfile := path.Split(path.Clean(newpath))
-	oldd, err := navigateTo(olddir)
+	oldd, err := f.navigateTo(olddir, c)
 	if err == nil {
-		var newd dir
-		newd, err = navigateTo(newdir)
-		f, err := oldd.get(oldfile)
-		if err == nil {
-			type i interface {
-				move(string, *directory) error
-			}
-			err = f.(i).move(newfile, newd)
+		var newd *directory
+		if newd, err = f.navigateTo(newdir, c); err == nil {
+			f.renameMu.Lock()
+			err = oldd.move(oldfile, newd, newfile, c)
+			f.renameMu.Unlock()
 		}
 	}
 	if err != nil {
@@ -307,42 +443,66 @@ func Rename(oldpath, newpath string) error {
 			err,
 		}
 	}
+	f.notify(EventRename, oldpath)
+	f.notify(EventCreate, newpath)
 	return nil
 }
 
 func SameFile(f, g os.FileInfo) bool {
-	return f == g
-}
-
-func Setenv(key, value string) error {
-	return ErrUnsupported
+	return nodeOf(f) == nodeOf(g)
 }
 
-func Symlink(oldname, newname string) error {
-	return &LinkError{
-		"symlink",
-		oldname,
-		newname,
-		ErrUnsupported,
+func (f *FS) Symlink(oldname, newname string) (err error) {
+	defer f.record(Entry{Op: OpSymlink, Path: oldname, NewPath: newname}, &err)
+	if err := f.fault(OpSymlink, newname); err != nil {
+		return &LinkError{
+			"symlink",
+			oldname,
+			newname,
+			err,
+		}
+	}
+	err = ErrNotExist
+	if oldname != "" {
+		var (
+			d    *directory
+			name string
+		)
+		c := f.getCred()
+		if d, name, err = f.resolve(newname, false, c); err == nil {
+			err = d.symlink(name, oldname, c)
+		}
+	}
+	if err != nil {
+		return &LinkError{
+			"symlink",
+			oldname,
+			newname,
+			err,
+		}
 	}
+	f.notify(EventCreate, newname)
+	return nil
 }
 
 func TempDir() string {
 	return "/tmp"
 }
 
-func Truncate(name string, size int64) error {
-	f, err := getFile(name)
+func (f *FS) Truncate(name string, size int64) (err error) {
+	defer f.record(Entry{Op: OpTruncate, Path: name}, &err)
+	if err := f.fault(OpTruncate, name); err != nil {
+		return &PathError{
+			"truncate",
+			name,
+			err,
+		}
+	}
+	fi, err := f.getFile(name, true)
 	if err == nil {
-		if f, ok := f.(*file); ok {
-			if canWrite(f.Mode()) {
-				if size < int64(len(f.Contents)) {
-					f.Contents = f.Contents[:size]
-				} else {
-					c := f.Contents
-					f.Contents = make([]byte, size)
-					copy(f.Contents, c)
-				}
+		if fi, ok := fi.(*bfile); ok {
+			if fi.checkAccess(f.getCred(), permWrite) {
+				err = fi.truncate(size)
 			} else {
 				err = ErrPermission
 			}
@@ -357,9 +517,6 @@ func Truncate(name string, size int64) error {
 			err,
 		}
 	}
+	f.notify(EventWrite, name)
 	return nil
 }
-
-func Unsetenv(_ string) error {
-	return ErrUnsupported
-}
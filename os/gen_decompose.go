@@ -0,0 +1,187 @@
+//go:build ignore
+
+// This program generates decompose.go from the UnicodeData.txt file of the
+// Unicode Character Database. Run it with go generate.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	version := flag.String("version", "15.0.0", "Unicode version of the tables")
+	data := flag.String("data", "", "local UnicodeData.txt to read instead of downloading it")
+	output := flag.String("output", "decompose.go", "file to write the tables to")
+	flag.Parse()
+	r, err := open(*data, *version)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+	decomps, classes, err := parse(r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(*version, decomps, classes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func open(data, version string) (io.ReadCloser, error) {
+	if data != "" {
+		return os.Open(data)
+	}
+	url := "https://www.unicode.org/Public/" + version + "/ucd/UnicodeData.txt"
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// parse reads the canonical decomposition mappings and the non-zero canonical
+// combining classes from UnicodeData.txt.
+func parse(r io.Reader) (map[rune][]rune, map[rune]uint8, error) {
+	decomps := make(map[rune][]rune)
+	classes := make(map[rune]uint8)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Split(s.Text(), ";")
+		if len(fields) < 6 {
+			continue
+		}
+		c, err := strconv.ParseUint(fields[0], 16, 32)
+		if err != nil {
+			return nil, nil, err
+		}
+		ccc, err := strconv.ParseUint(fields[3], 10, 8)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ccc != 0 {
+			classes[rune(c)] = uint8(ccc)
+		}
+		// compatibility mappings start with a tag, such as <compat>
+		if fields[5] == "" || fields[5][0] == '<' {
+			continue
+		}
+		var d []rune
+		for _, f := range strings.Fields(fields[5]) {
+			r, err := strconv.ParseUint(f, 16, 32)
+			if err != nil {
+				return nil, nil, err
+			}
+			d = append(d, rune(r))
+		}
+		decomps[rune(c)] = d
+	}
+	return decomps, classes, s.Err()
+}
+
+// full recursively applies the decomposition mappings to r.
+func full(decomps map[rune][]rune, r rune) []rune {
+	d, ok := decomps[r]
+	if !ok {
+		return []rune{r}
+	}
+	var rs []rune
+	for _, s := range d {
+		rs = append(rs, full(decomps, s)...)
+	}
+	return rs
+}
+
+func quote(rs []rune) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range rs {
+		switch {
+		case r >= 0x20 && r < 0x7f && r != '"' && r != '\\':
+			sb.WriteRune(r)
+		case r < 0x10000:
+			fmt.Fprintf(&sb, "\\u%04x", r)
+		default:
+			fmt.Fprintf(&sb, "\\U%08x", r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func sortRunes(rs []rune) []rune {
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i] < rs[j]
+	})
+	return rs
+}
+
+func generate(version string, decomps map[rune][]rune, classes map[rune]uint8) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen_decompose.go from Unicode %s UnicodeData.txt; DO NOT EDIT.\n\n", version)
+	fmt.Fprintf(&buf, "package os\n\n")
+	fmt.Fprintf(&buf, "// unicodeVersion is the version of Unicode from which the tables are\n// generated.\n")
+	fmt.Fprintf(&buf, "const unicodeVersion = %q\n\n", version)
+	fmt.Fprintf(&buf, "// decompositions maps characters to their full canonical decompositions. The\n")
+	fmt.Fprintf(&buf, "// algorithmic decompositions of Hangul syllables are not included.\n")
+	fmt.Fprintf(&buf, "var decompositions = map[rune]string{\n")
+	keys := make([]rune, 0, len(decomps))
+	for r := range decomps {
+		keys = append(keys, r)
+	}
+	for n, r := range sortRunes(keys) {
+		if n%4 == 0 {
+			buf.WriteByte('\t')
+		} else {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "0x%04x: %s,", r, quote(full(decomps, r)))
+		if n%4 == 3 {
+			buf.WriteByte('\n')
+		}
+	}
+	if len(decomps)%4 != 0 {
+		buf.WriteByte('\n')
+	}
+	fmt.Fprintf(&buf, "}\n\n")
+	fmt.Fprintf(&buf, "// combiningClasses maps characters to their non-zero canonical combining\n// classes.\n")
+	fmt.Fprintf(&buf, "var combiningClasses = map[rune]uint8{\n")
+	keys = keys[:0]
+	for r := range classes {
+		keys = append(keys, r)
+	}
+	for n, r := range sortRunes(keys) {
+		if n%8 == 0 {
+			buf.WriteByte('\t')
+		} else {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "0x%04x: %d,", r, classes[r])
+		if n%8 == 7 {
+			buf.WriteByte('\n')
+		}
+	}
+	if len(classes)%8 != 0 {
+		buf.WriteByte('\n')
+	}
+	fmt.Fprintf(&buf, "}\n")
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,158 @@
+package os
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// ImportDir recursively copies the given host directory into the filesystem at
+// fakePath, preserving the contents, modes and modification times of files and
+// directories, and the targets of symbolic links.
+func (f *FS) ImportDir(hostPath, fakePath string) error {
+	if err := f.MkdirAll(fakePath, 0777); err != nil {
+		return err
+	}
+	var dirs dirAttrs
+	err := filepath.Walk(hostPath, func(host string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(hostPath, host)
+		if err != nil {
+			return err
+		}
+		p := path.Join(fakePath, filepath.ToSlash(rel))
+		switch mode := fi.Mode(); {
+		case mode.IsDir():
+			if rel != "." {
+				if err := f.Mkdir(p, 0700); err != nil {
+					return err
+				}
+			}
+			dirs.add(p, fi)
+		case mode&os.ModeSymlink != 0:
+			target, err := os.Readlink(host)
+			if err != nil {
+				return err
+			}
+			return f.Symlink(target, p)
+		case mode.IsRegular():
+			data, err := os.ReadFile(host)
+			if err != nil {
+				return err
+			}
+			f.WriteBytes(p, mode, data)
+			return f.Chtimes(p, fi.ModTime(), fi.ModTime())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return dirs.apply(f)
+}
+
+type dirAttr struct {
+	path    string
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// dirAttrs records the modes and modification times of directories being
+// populated, which are set once they have been filled so that read-only
+// directories can be written to first.
+type dirAttrs []dirAttr
+
+func (d *dirAttrs) add(p string, fi os.FileInfo) {
+	*d = append(*d, dirAttr{p, fi.Mode() &^ os.ModeDir, fi.ModTime()})
+}
+
+func (d dirAttrs) apply(f *FS) error {
+	for n := len(d) - 1; n >= 0; n-- {
+		if err := f.Chmod(d[n].path, d[n].mode); err != nil {
+			return err
+		}
+		if err := f.Chtimes(d[n].path, d[n].modTime, d[n].modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportDir recursively copies the directory at fakePath in the filesystem
+// onto the host at hostPath, preserving the contents, modes and modification
+// times of files and directories, and the targets of symbolic links.
+func (f *FS) ExportDir(fakePath, hostPath string) error {
+	fi, err := f.Lstat(fakePath)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return &PathError{
+			"export",
+			fakePath,
+			ErrIsNotDir,
+		}
+	}
+	if err := os.MkdirAll(hostPath, 0700); err != nil {
+		return err
+	}
+	return f.export(fakePath, hostPath, fi)
+}
+
+const hostModeMask = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+func (f *FS) export(fake, host string, fi os.FileInfo) error {
+	switch mode := fi.Mode(); {
+	case mode.IsDir():
+		if err := os.Mkdir(host, 0700); err != nil && !os.IsExist(err) {
+			return err
+		}
+		d, err := f.Open(fake)
+		if err != nil {
+			return err
+		}
+		fis, err := d.Readdir(-1)
+		d.Close()
+		if err != nil {
+			return err
+		}
+		for _, cfi := range fis {
+			if err := f.export(path.Join(fake, cfi.Name()), filepath.Join(host, cfi.Name()), cfi); err != nil {
+				return err
+			}
+		}
+	case mode&os.ModeSymlink != 0:
+		target, err := f.Readlink(fake)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, host)
+	case mode.IsRegular():
+		src, err := f.Open(fake)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		dst, err := os.OpenFile(host, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dst, src)
+		if cerr := dst.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	if err := os.Chmod(host, fi.Mode()&hostModeMask); err != nil {
+		return err
+	}
+	return os.Chtimes(host, fi.ModTime(), fi.ModTime())
+}
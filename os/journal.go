@@ -0,0 +1,207 @@
+package os
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Entry records an operation performed on a filesystem.
+type Entry struct {
+	Op Op
+	// Path is the absolute path of the file operated on. For Link, Rename
+	// and Symlink it is the old path, or link target, with the new path
+	// being recorded in NewPath.
+	Path    string
+	NewPath string
+	// Flag is the flag given to OpenFile.
+	Flag int
+	// Bytes is the number of bytes read or written.
+	Bytes int64
+	Err   error
+}
+
+func (e Entry) String() string {
+	var sb strings.Builder
+	sb.WriteString(string(e.Op))
+	sb.WriteByte(' ')
+	sb.WriteString(e.Path)
+	if e.NewPath != "" {
+		sb.WriteByte(' ')
+		sb.WriteString(e.NewPath)
+	}
+	if e.Flag != 0 {
+		fmt.Fprintf(&sb, " flag=%#x", e.Flag)
+	}
+	if e.Bytes != 0 {
+		fmt.Fprintf(&sb, " bytes=%d", e.Bytes)
+	}
+	if e.Err != nil {
+		sb.WriteString(": ")
+		sb.WriteString(e.Err.Error())
+	}
+	return sb.String()
+}
+
+// Journal is a record of the operations performed on a filesystem, created
+// with FS.StartJournal.
+type Journal struct {
+	mu      sync.Mutex
+	entries Entries
+}
+
+// StartJournal begins recording the operations performed on the filesystem,
+// and on the files opened from it, to a new Journal, replacing any journal
+// already being recorded to.
+func (f *FS) StartJournal() *Journal {
+	j := new(Journal)
+	f.optMu.Lock()
+	f.journal = j
+	f.optMu.Unlock()
+	return j
+}
+
+// StopJournal stops recording operations, returning the journal that was being
+// recorded to, if any.
+func (f *FS) StopJournal() *Journal {
+	f.optMu.Lock()
+	defer f.optMu.Unlock()
+	j := f.journal
+	f.journal = nil
+	return j
+}
+
+func (t *tree) getJournal() *Journal {
+	t.optMu.RLock()
+	defer t.optMu.RUnlock()
+	return t.journal
+}
+
+// Entries returns a copy of the entries recorded so far.
+func (j *Journal) Entries() Entries {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append(Entries{}, j.entries...)
+}
+
+// Reset removes all recorded entries.
+func (j *Journal) Reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = nil
+}
+
+func (j *Journal) add(e Entry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, e)
+}
+
+// record adds an entry, with the error pointed to by err, to the journal of
+// the filesystem, if there is one. Relative paths in the entry are made
+// absolute.
+func (f *FS) record(e Entry, err *error) {
+	j := f.getJournal()
+	if j == nil {
+		return
+	}
+	e.Path = f.abs(e.Path)
+	if e.NewPath != "" {
+		e.NewPath = f.abs(e.NewPath)
+	}
+	e.Err = *err
+	j.add(e)
+}
+
+// record adds an entry for an operation on the file, with the number of bytes
+// pointed to by n, if not nil, and the error pointed to by err, to the journal
+// of the filesystem, if there is one.
+func (f *File) record(op Op, n *int, err *error) {
+	if f == nil {
+		return
+	}
+	j := f.fs.getJournal()
+	if j == nil {
+		return
+	}
+	e := Entry{
+		Op:   op,
+		Path: f.path,
+		Err:  *err,
+	}
+	if n != nil {
+		e.Bytes = int64(*n)
+	}
+	j.add(e)
+}
+
+// Entries is a list of journal entries.
+type Entries []Entry
+
+// Filter returns the entries for which fn returns true.
+func (es Entries) Filter(fn func(Entry) bool) Entries {
+	var filtered Entries
+	for _, e := range es {
+		if fn(e) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// Ops returns the entries for the given operations.
+func (es Entries) Ops(ops ...Op) Entries {
+	return es.Filter(func(e Entry) bool {
+		for _, op := range ops {
+			if e.Op == op {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Mutations returns the entries for operations that can modify the
+// filesystem, including opens that create or truncate files.
+func (es Entries) Mutations() Entries {
+	return es.Filter(func(e Entry) bool {
+		switch e.Op {
+		case OpOpen:
+			return e.Flag&(O_CREATE|O_TRUNC) != 0
+		case OpChmod, OpChown, OpChtimes, OpLink, OpMkdir, OpRemove, OpRename, OpSymlink, OpTruncate, OpWrite:
+			return true
+		}
+		return false
+	})
+}
+
+// Match compares the entries against the wanted entries, returning an error
+// describing the first difference.
+//
+// The Op, Path and NewPath of each entry must be equal to those wanted, while
+// the Flag and Bytes are only compared when wanted is not zero, and the Err
+// is only compared, with errors.Is, when wanted is not nil.
+func (es Entries) Match(want ...Entry) error {
+	for n, w := range want {
+		if n >= len(es) {
+			return fmt.Errorf("entry %d: missing, want %s", n, w)
+		}
+		e := es[n]
+		if e.Op != w.Op || e.Path != w.Path || e.NewPath != w.NewPath || w.Flag != 0 && e.Flag != w.Flag || w.Bytes != 0 && e.Bytes != w.Bytes || w.Err != nil && !errors.Is(e.Err, w.Err) {
+			return fmt.Errorf("entry %d: got %s, want %s", n, e, w)
+		}
+	}
+	if len(es) > len(want) {
+		return fmt.Errorf("entry %d: unexpected %s", len(want), es[len(want)])
+	}
+	return nil
+}
+
+// Expect fails the test if the entries do not Match those wanted.
+func (es Entries) Expect(t TB, want ...Entry) {
+	t.Helper()
+	if err := es.Match(want...); err != nil {
+		t.Errorf("journal: %s", err)
+	}
+}
@@ -0,0 +1,199 @@
+package os
+
+import (
+	"math"
+	"os"
+)
+
+const (
+	LOCK_SH = 0x1
+	LOCK_EX = 0x2
+	LOCK_NB = 0x4
+	LOCK_UN = 0x8
+)
+
+type lockKind uint8
+
+const (
+	lockWhole lockKind = iota
+	lockRange
+)
+
+// fileLock is an advisory lock held, or waited for, by an open file.
+type fileLock struct {
+	node       os.FileInfo
+	owner      *File
+	kind       lockKind
+	start, end int64
+	exclusive  bool
+}
+
+// conflicts returns true if the locks cannot both be held.
+func (l *fileLock) conflicts(m *fileLock) bool {
+	return l.node == m.node && l.kind == m.kind && l.owner != m.owner && (l.exclusive || m.exclusive) && l.start < m.end && m.start < l.end
+}
+
+// Flock applies or removes an advisory lock on the whole file, as with
+// flock(2). The how argument is one of LOCK_SH, for a shared lock, LOCK_EX, for
+// an exclusive lock, or LOCK_UN, to remove the lock.
+//
+// Locks are held by the File, and are released when it is closed. Unless
+// LOCK_NB is also given, Flock blocks until the lock can be acquired, failing
+// with ErrDeadlock if waiting would never finish; with LOCK_NB, ErrWouldBlock
+// is returned instead of waiting.
+func (f *File) Flock(how int) error {
+	return f.lock("flock", lockWhole, 0, math.MaxInt64, how)
+}
+
+// LockRange applies or removes an advisory lock on length bytes of the file
+// starting at off, as with fcntl(2) record locks. A length of zero extends the
+// range to the end of the file, however large it grows. The how argument is
+// as for Flock.
+//
+// Range locks are independent of the whole file locks of Flock. Locking or
+// unlocking a range replaces the locks held by the File on the parts of the
+// range.
+func (f *File) LockRange(off, length int64, how int) error {
+	end := off + length
+	if length == 0 {
+		end = math.MaxInt64
+	}
+	if off < 0 || length < 0 || end < off {
+		return &PathError{
+			"fcntl",
+			f.name,
+			ErrInvalid,
+		}
+	}
+	return f.lock("fcntl", lockRange, off, end, how)
+}
+
+func (f *File) lock(op string, kind lockKind, start, end int64, how int) error {
+	if err := f.validPath(op); err != nil {
+		return err
+	}
+	var err error
+	switch how &^ LOCK_NB {
+	case LOCK_UN:
+		f.fs.unlock(f, kind, start, end)
+	case LOCK_SH, LOCK_EX:
+		err = f.fs.acquire(&fileLock{
+			node:      f.fi,
+			owner:     f,
+			kind:      kind,
+			start:     start,
+			end:       end,
+			exclusive: how&LOCK_EX != 0,
+		}, how&LOCK_NB == 0)
+	default:
+		err = ErrInvalid
+	}
+	if err != nil {
+		return &PathError{
+			op,
+			f.name,
+			err,
+		}
+	}
+	return nil
+}
+
+// acquire adds the lock, waiting for conflicting locks to be released if wait
+// is true.
+func (t *tree) acquire(req *fileLock, wait bool) error {
+	t.lockMu.Lock()
+	defer t.lockMu.Unlock()
+	for t.isLocked(req) {
+		if !wait {
+			return ErrWouldBlock
+		}
+		if t.deadlocks(req) {
+			return ErrDeadlock
+		}
+		t.lockWaits[req.owner] = req
+		t.lockCond.Wait()
+		delete(t.lockWaits, req.owner)
+	}
+	t.unlockLocked(req.owner, req.kind, req.start, req.end)
+	t.locks = append(t.locks, req)
+	return nil
+}
+
+// isLocked must be called with the lockMu held
+func (t *tree) isLocked(req *fileLock) bool {
+	for _, l := range t.locks {
+		if l.conflicts(req) {
+			return true
+		}
+	}
+	return false
+}
+
+// deadlocks returns true if the owner of the request holds a lock that,
+// through a chain of waiting owners, blocks the holders of the locks the
+// request is waiting for.
+//
+// Must be called with the lockMu held.
+func (t *tree) deadlocks(req *fileLock) bool {
+	seen := make(map[*File]bool)
+	var check func(*fileLock) bool
+	check = func(r *fileLock) bool {
+		for _, l := range t.locks {
+			if !l.conflicts(r) {
+				continue
+			}
+			if l.owner == req.owner {
+				return true
+			}
+			if seen[l.owner] {
+				continue
+			}
+			seen[l.owner] = true
+			if w, ok := t.lockWaits[l.owner]; ok && check(w) {
+				return true
+			}
+		}
+		return false
+	}
+	return check(req)
+}
+
+func (t *tree) unlock(owner *File, kind lockKind, start, end int64) {
+	t.lockMu.Lock()
+	defer t.lockMu.Unlock()
+	t.unlockLocked(owner, kind, start, end)
+}
+
+// unlockLocked removes the locks of the given kind held by the owner from the
+// range, splitting any locks that extend beyond it.
+//
+// Must be called with the lockMu held.
+func (t *tree) unlockLocked(owner *File, kind lockKind, start, end int64) {
+	var locks []*fileLock
+	for _, l := range t.locks {
+		if l.owner != owner || l.kind != kind || l.end <= start || end <= l.start {
+			locks = append(locks, l)
+			continue
+		}
+		if l.start < start {
+			m := *l
+			m.end = start
+			locks = append(locks, &m)
+		}
+		if end < l.end {
+			m := *l
+			m.start = end
+			locks = append(locks, &m)
+		}
+	}
+	t.locks = locks
+	t.lockCond.Broadcast()
+}
+
+// releaseLocks removes all of the locks held by the file.
+func (t *tree) releaseLocks(owner *File) {
+	t.lockMu.Lock()
+	defer t.lockMu.Unlock()
+	t.unlockLocked(owner, lockWhole, 0, math.MaxInt64)
+	t.unlockLocked(owner, lockRange, 0, math.MaxInt64)
+}
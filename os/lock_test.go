@@ -0,0 +1,237 @@
+package os
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// openLockers opens the file n times, each File acting as a separate process
+// holding locks.
+func openLockers(t *testing.T, f *FS, name string, n int) []*File {
+	t.Helper()
+	fs := make([]*File, n)
+	for i := range fs {
+		fl, err := f.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { fl.Close() })
+		fs[i] = fl
+	}
+	return fs
+}
+
+// waitForWaiters waits until n files are blocked waiting for locks.
+func waitForWaiters(t *testing.T, f *FS, n int) {
+	t.Helper()
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		f.lockMu.Lock()
+		l := len(f.lockWaits)
+		f.lockMu.Unlock()
+		if l == n {
+			return
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("timed out waiting for %d lock waiters, have %d", n, l)
+		}
+	}
+}
+
+func TestLockConflicts(t *testing.T) {
+	type lock struct {
+		whole       bool
+		off, length int64
+		how         int
+	}
+	for n, test := range [...]struct {
+		first, second lock
+		err           error
+	}{
+		{lock{true, 0, 0, LOCK_SH}, lock{true, 0, 0, LOCK_SH}, nil},
+		{lock{true, 0, 0, LOCK_SH}, lock{true, 0, 0, LOCK_EX}, ErrWouldBlock},
+		{lock{true, 0, 0, LOCK_EX}, lock{true, 0, 0, LOCK_SH}, ErrWouldBlock},
+		{lock{true, 0, 0, LOCK_EX}, lock{false, 0, 0, LOCK_EX}, nil},
+		{lock{false, 0, 10, LOCK_SH}, lock{false, 5, 10, LOCK_SH}, nil},
+		{lock{false, 0, 10, LOCK_SH}, lock{false, 5, 10, LOCK_EX}, ErrWouldBlock},
+		{lock{false, 0, 10, LOCK_EX}, lock{false, 10, 10, LOCK_EX}, nil},
+		{lock{false, 10, 0, LOCK_EX}, lock{false, math.MaxInt32, 1, LOCK_SH}, ErrWouldBlock},
+		{lock{false, 0, 0, LOCK_EX}, lock{false, -1, 1, LOCK_SH}, ErrInvalid},
+		{lock{true, 0, 0, LOCK_SH}, lock{true, 0, 0, LOCK_SH | LOCK_EX}, ErrInvalid},
+	} {
+		f := New()
+		f.WriteBytes("/file", 0644, nil)
+		fs := openLockers(t, f, "/file", 2)
+		var errs [2]error
+		for i, l := range [2]lock{test.first, test.second} {
+			if l.whole {
+				errs[i] = fs[i].Flock(l.how | LOCK_NB)
+			} else {
+				errs[i] = fs[i].LockRange(l.off, l.length, l.how|LOCK_NB)
+			}
+		}
+		if errs[0] != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, errs[0])
+		} else if !errors.Is(errs[1], test.err) {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.err, errs[1])
+		}
+	}
+}
+
+func TestLockWait(t *testing.T) {
+	f := New()
+	f.WriteBytes("/file", 0644, nil)
+	fs := openLockers(t, f, "/file", 4)
+	if err := fs[0].Flock(LOCK_EX); err != nil {
+		t.Fatal(err)
+	}
+	shared := make(chan error)
+	for _, fl := range fs[1:3] {
+		go func(fl *File) { shared <- fl.Flock(LOCK_SH) }(fl)
+	}
+	waitForWaiters(t, f, 2)
+	if err := fs[0].Flock(LOCK_UN); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-shared; err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	exclusive := make(chan error)
+	go func() { exclusive <- fs[3].Flock(LOCK_EX) }()
+	waitForWaiters(t, f, 1)
+	fs[1].Close()
+	select {
+	case err := <-exclusive:
+		t.Fatalf("exclusive lock acquired with a shared lock held: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	fs[2].Close()
+	if err := <-exclusive; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestLockDeadlock(t *testing.T) {
+	for n, test := range [...]struct {
+		first, second, wait, request func(*File) error
+	}{
+		{
+			// both hold shared locks and both try to upgrade
+			func(fl *File) error { return fl.Flock(LOCK_SH) },
+			func(fl *File) error { return fl.Flock(LOCK_SH) },
+			func(fl *File) error { return fl.Flock(LOCK_EX) },
+			func(fl *File) error { return fl.Flock(LOCK_EX) },
+		},
+		{
+			// each waits for the range held by the other
+			func(fl *File) error { return fl.LockRange(0, 10, LOCK_EX) },
+			func(fl *File) error { return fl.LockRange(10, 10, LOCK_EX) },
+			func(fl *File) error { return fl.LockRange(10, 10, LOCK_EX) },
+			func(fl *File) error { return fl.LockRange(5, 10, LOCK_EX) },
+		},
+	} {
+		f := New()
+		f.WriteBytes("/file", 0644, nil)
+		fs := openLockers(t, f, "/file", 2)
+		if err := test.first(fs[0]); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+		if err := test.second(fs[1]); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+		waiting := make(chan error)
+		go func() { waiting <- test.wait(fs[0]) }()
+		waitForWaiters(t, f, 1)
+		if err := test.request(fs[1]); !errors.Is(err, ErrDeadlock) {
+			t.Errorf("test %d: expecting ErrDeadlock, got %v", n+1, err)
+		}
+		fs[1].Close()
+		if err := <-waiting; err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+		}
+	}
+}
+
+func TestLockRangeSplit(t *testing.T) {
+	for n, test := range [...]struct {
+		off, length int64
+		ranges      [][2]int64
+	}{
+		{10, 10, [][2]int64{{0, 10}, {20, 30}}},
+		{0, 10, [][2]int64{{10, 30}}},
+		{20, 0, [][2]int64{{0, 20}}},
+		{0, 0, nil},
+		{30, 10, [][2]int64{{0, 30}}},
+	} {
+		f := New()
+		f.WriteBytes("/file", 0644, nil)
+		fs := openLockers(t, f, "/file", 2)
+		if err := fs[0].LockRange(0, 30, LOCK_EX); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+		if err := fs[0].LockRange(test.off, test.length, LOCK_UN); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+		var ranges [][2]int64
+		f.lockMu.Lock()
+		for _, l := range f.locks {
+			if l.owner == fs[0] {
+				ranges = append(ranges, [2]int64{l.start, l.end})
+			}
+		}
+		f.lockMu.Unlock()
+		if len(ranges) != len(test.ranges) {
+			t.Errorf("test %d: expecting ranges %v, got %v", n+1, test.ranges, ranges)
+			continue
+		}
+		for i, r := range ranges {
+			if r != test.ranges[i] {
+				t.Errorf("test %d: expecting ranges %v, got %v", n+1, test.ranges, ranges)
+				break
+			}
+		}
+		for _, r := range test.ranges {
+			if err := fs[1].LockRange(r[0], r[1]-r[0], LOCK_SH|LOCK_NB); !errors.Is(err, ErrWouldBlock) {
+				t.Errorf("test %d: expecting range %v to remain locked, got %v", n+1, r, err)
+			}
+		}
+		if test.length > 0 {
+			if err := fs[1].LockRange(test.off, test.length, LOCK_SH|LOCK_NB); err != nil {
+				t.Errorf("test %d: expecting unlocked range to be available, got %v", n+1, err)
+			}
+		}
+	}
+}
+
+func TestLockReleaseOnClose(t *testing.T) {
+	f := New()
+	f.WriteBytes("/file", 0644, nil)
+	fs := openLockers(t, f, "/file", 2)
+	if err := fs[0].Flock(LOCK_EX); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs[0].LockRange(0, 0, LOCK_EX); err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan error)
+	go func() {
+		if err := fs[1].Flock(LOCK_EX); err != nil {
+			acquired <- err
+			return
+		}
+		acquired <- fs[1].LockRange(0, 0, LOCK_EX)
+	}()
+	waitForWaiters(t, f, 1)
+	if err := fs[0].Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-acquired; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := fs[0].Flock(LOCK_UN); !errors.Is(err, ErrClosed) {
+		t.Errorf("expecting ErrClosed locking a closed file, got %v", err)
+	}
+}
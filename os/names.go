@@ -0,0 +1,212 @@
+package os
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Personality determines how the names of entries within directories are
+// matched, allowing the behaviour of other operating systems to be tested.
+type Personality uint8
+
+const (
+	// CaseInsensitive matches names regardless of case, while preserving the
+	// case with which they were created.
+	CaseInsensitive Personality = 1 << iota
+	// NormalizationInsensitive matches names regardless of their Unicode
+	// normalisation form, while preserving the form with which they were
+	// created.
+	NormalizationInsensitive
+	// DecomposeNames stores names in Unicode canonical decomposed form (NFD),
+	// and matches names regardless of their normalisation form.
+	DecomposeNames
+)
+
+const (
+	// Posix matches names byte for byte. This is the default.
+	Posix Personality = 0
+	// NTFS matches names in the manner of Windows.
+	NTFS = CaseInsensitive
+	// HFSPlus matches and stores names in the manner of HFS+.
+	HFSPlus = CaseInsensitive | DecomposeNames
+	// APFS matches names in the manner of the default macOS APFS format.
+	APFS = CaseInsensitive | NormalizationInsensitive
+)
+
+// SetPersonality sets how the names of entries within directories are matched
+// and stored. It should be set before the filesystem is populated, as existing
+// names that would then match each other are not merged.
+func (f *FS) SetPersonality(p Personality) {
+	f.optMu.Lock()
+	f.personality = p
+	f.optMu.Unlock()
+	f.renameMu.Lock()
+	defer f.renameMu.Unlock()
+	f.root.reindex(p)
+}
+
+func (t *tree) getPersonality() Personality {
+	t.optMu.RLock()
+	defer t.optMu.RUnlock()
+	return t.personality
+}
+
+// storeName returns the form in which a new name is to be stored.
+func (t *tree) storeName(name string) string {
+	if t.getPersonality()&DecomposeNames != 0 {
+		return decompose(name)
+	}
+	return name
+}
+
+// key returns the form of a name used to determine whether it matches another.
+func (p Personality) key(name string) string {
+	if p&(NormalizationInsensitive|DecomposeNames) != 0 {
+		name = decompose(name)
+	}
+	if p&CaseInsensitive != 0 {
+		name = strings.Map(foldRune, name)
+	}
+	return name
+}
+
+// foldRune returns the smallest rune that is equivalent to r under Unicode
+// simple case folding.
+func foldRune(r rune) rune {
+	m := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < m {
+			m = f
+		}
+	}
+	return m
+}
+
+// lookup returns the entry of the directory matching name, along with the name
+// under which it is stored.
+//
+// Must be called with the directory lock held.
+func (d *directory) lookup(name string) (string, os.FileInfo, bool) {
+	if fi, ok := d.Contents[name]; ok {
+		return name, fi, true
+	}
+	p := d.tree.getPersonality()
+	if p == Posix {
+		return name, nil, false
+	}
+	if n, ok := d.keys[p.key(name)]; ok {
+		return n, d.Contents[n], true
+	}
+	return name, nil, false
+}
+
+// set adds an entry to the directory under the given name.
+//
+// Must be called with the directory lock held.
+func (d *directory) set(name string, fi os.FileInfo) {
+	d.Contents[name] = fi
+	d.index(d.tree.getPersonality(), name)
+}
+
+// unset removes the entry with the given name from the directory.
+//
+// Must be called with the directory lock held.
+func (d *directory) unset(name string) {
+	delete(d.Contents, name)
+	if d.keys == nil {
+		return
+	}
+	key := d.tree.getPersonality().key(name)
+	if d.keys[key] == name {
+		delete(d.keys, key)
+	}
+}
+
+// index records the key of the name under the given personality.
+//
+// Must be called with the directory lock held.
+func (d *directory) index(p Personality, name string) {
+	if p == Posix {
+		return
+	}
+	if d.keys == nil {
+		d.keys = make(map[string]string)
+	}
+	d.keys[p.key(name)] = name
+}
+
+// reindex rebuilds the keys of the directory and its descendants for the
+// given personality.
+//
+// Must be called with the FS renameMu held.
+func (d *directory) reindex(p Personality) {
+	d.mu.Lock()
+	d.keys = nil
+	var dirs []*directory
+	for name, fi := range d.Contents {
+		d.index(p, name)
+		if e, ok := fi.(*directory); ok {
+			dirs = append(dirs, e)
+		}
+	}
+	d.mu.Unlock()
+	for _, e := range dirs {
+		e.reindex(p)
+	}
+}
+
+//go:generate go run gen_decompose.go
+
+const (
+	hangulBase  = 0xac00
+	hangulCount = 11172
+	hangulL     = 0x1100
+	hangulV     = 0x1161
+	hangulT     = 0x11a7
+	hangulVT    = 21 * 28
+)
+
+// decompose returns the canonical decomposition (NFD) of the name.
+func decompose(name string) string {
+	ascii := true
+	for i := 0; i < len(name); i++ {
+		if name[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return name
+	}
+	rs := make([]rune, 0, len(name))
+	for _, r := range name {
+		if d, ok := decompositions[r]; ok {
+			rs = append(rs, []rune(d)...)
+		} else if s := r - hangulBase; s >= 0 && s < hangulCount {
+			rs = append(rs, hangulL+s/hangulVT, hangulV+s%hangulVT/28)
+			if t := s % 28; t > 0 {
+				rs = append(rs, hangulT+t)
+			}
+		} else {
+			rs = append(rs, r)
+		}
+	}
+	for i := 0; i < len(rs); i++ {
+		if combiningClasses[rs[i]] == 0 {
+			continue
+		}
+		j := i + 1
+		for j < len(rs) && combiningClasses[rs[j]] != 0 {
+			j++
+		}
+		marks := rs[i:j]
+		sort.SliceStable(marks, func(a, b int) bool {
+			return combiningClasses[marks[a]] < combiningClasses[marks[b]]
+		})
+		i = j
+	}
+	return string(rs)
+}
@@ -0,0 +1,46 @@
+package os
+
+import "testing"
+
+func TestDecompose(t *testing.T) {
+	for _, test := range [...]struct {
+		name, nfd string
+	}{
+		{"plain.txt", "plain.txt"},
+		{"caf\u00e9", "cafe\u0301"},
+		{"\u304c", "\u304b\u3099"},
+		{"\u30d1\u30b9", "\u30cf\u309a\u30b9"},
+		{"\ud55c", "\u1112\u1161\u11ab"},
+		{"\u1ec7", "e\u0323\u0302"},
+		{"e\u0302\u0323", "e\u0323\u0302"},
+		{"\u212b", "A\u030a"},
+		{"\U0001d15e", "\U0001d157\U0001d165"},
+	} {
+		if got := decompose(test.name); got != test.nfd {
+			t.Errorf("%q: expecting %q, got %q", test.name, test.nfd, got)
+		}
+	}
+}
+
+func TestPersonalityLookup(t *testing.T) {
+	for _, test := range [...]struct {
+		personality  Personality
+		create, find string
+		found        bool
+	}{
+		{Posix, "Foo.txt", "foo.txt", false},
+		{NTFS, "Foo.txt", "foo.txt", true},
+		{NTFS, "\u304c", "\u304b\u3099", false},
+		{APFS, "\u304c", "\u304b\u3099", true},
+		{APFS, "\u304b\u3099", "\u304c", true},
+		{APFS, "Caf\u00e9", "CAFE\u0301", true},
+		{HFSPlus, "\u30d1", "\u30cf\u309a", true},
+	} {
+		f := New()
+		f.SetPersonality(test.personality)
+		f.WriteBytes("/"+test.create, 0644, nil)
+		if _, err := f.Stat("/" + test.find); (err == nil) != test.found {
+			t.Errorf("%d: %q: expecting found %v, got %v", test.personality, test.find, test.found, err)
+		}
+	}
+}
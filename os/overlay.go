@@ -0,0 +1,151 @@
+package os
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// NewOverlay creates a filesystem whose initial contents are those of the
+// given host directory, which is used as a read-only lower layer.
+//
+// Directories and files are copied from the host into memory as they are
+// first accessed; all writes, removals and renames happen only in memory and
+// the host directory is never modified.
+func NewOverlay(dir string) (*FS, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &PathError{
+			"overlay",
+			dir,
+			ErrIsNotDir,
+		}
+	}
+	return newFS(&directory{
+		node: node{
+			mode:    fi.Mode(),
+			nlink:   1,
+			atime:   fi.ModTime(),
+			modTime: fi.ModTime(),
+			ctime:   fi.ModTime(),
+		},
+		Contents: make(map[string]os.FileInfo),
+		lower:    dir,
+	}), nil
+}
+
+// load populates the directory from the lower host directory the first time
+// it is called, returning any error encountered reading the host directory.
+//
+// Must not be called with the directory lock held.
+func (d *directory) load() error {
+	d.loaded.Do(func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.lower == "" {
+			return
+		}
+		entries, err := os.ReadDir(d.lower)
+		if err != nil {
+			d.loadErr = hostError(err)
+			return
+		}
+		for _, entry := range entries {
+			fi, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			// host names that match an earlier name under the
+			// personality of the filesystem are hidden
+			if _, _, ok := d.lookup(fi.Name()); ok {
+				continue
+			}
+			name := d.tree.storeName(fi.Name())
+			lower := filepath.Join(d.lower, fi.Name())
+			setNode := func(n *node) {
+				n.tree = d.tree
+				n.ino = d.tree.newIno()
+				n.mode = fi.Mode()
+				n.nlink = 1
+				n.uid = d.uid
+				n.gid = d.gid
+				n.atime = fi.ModTime()
+				n.modTime = fi.ModTime()
+				n.ctime = fi.ModTime()
+				n.name = name
+				n.parent = d
+			}
+			switch mode := fi.Mode(); {
+			case mode.IsDir():
+				e := &directory{
+					Contents: make(map[string]os.FileInfo),
+					lower:    lower,
+				}
+				setNode(&e.node)
+				d.set(name, e)
+				d.tree.quota.add(0, 1)
+			case mode&os.ModeSymlink != 0:
+				target, err := os.Readlink(lower)
+				if err != nil {
+					continue
+				}
+				l := &symlink{
+					target: target,
+				}
+				setNode(&l.node)
+				d.set(name, l)
+				d.tree.quota.add(0, 1)
+			case mode.IsRegular():
+				f := &bfile{
+					data: chunks{
+						size: fi.Size(),
+					},
+					lower: lower,
+				}
+				setNode(&f.node)
+				d.set(name, f)
+				d.tree.quota.add(allocatedSize(f.data.size), 1)
+			}
+		}
+		d.lower = ""
+	})
+	return d.loadErr
+}
+
+// load reads the contents of the file from the lower host file the first time
+// it is called, returning any error encountered reading the host file. Until
+// then, only the size of the host file is known.
+//
+// Must not be called with the file lock held.
+func (f *bfile) load() error {
+	f.loaded.Do(func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.lower == "" {
+			return
+		}
+		data, err := os.ReadFile(f.lower)
+		if err != nil {
+			f.loadErr = hostError(err)
+			return
+		}
+		allocated := f.allocated()
+		f.data = newChunks(data, true)
+		f.lower = ""
+		if f.nlink > 0 {
+			f.tree.quota.add(f.data.allocated()-allocated, 0)
+		}
+	})
+	return f.loadErr
+}
+
+// hostError strips the host path from an error returned by the os package, so
+// that it can be reported against the path within the filesystem.
+func hostError(err error) error {
+	if e, ok := err.(*os.PathError); ok {
+		return e.Err
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package os
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverlayHostErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewOverlay(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Stat("/file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "file")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range [...]struct {
+		op, path string
+		fn       func(string) error
+	}{
+		{"open", "/file", func(p string) error { _, err := f.Open(p); return err }},
+		{"open", "/dir", func(p string) error { _, err := f.Open(p); return err }},
+		{"stat", "/dir/child", func(p string) error { _, err := f.Stat(p); return err }},
+		{"truncate", "/file", func(p string) error { return f.Truncate(p, 0) }},
+	} {
+		err := test.fn(test.path)
+		var pe *PathError
+		if !errors.As(err, &pe) || pe.Op != test.op || pe.Path != test.path {
+			t.Errorf("%s %s: expecting PathError, got %v", test.op, test.path, err)
+		} else if !errors.Is(err, ErrNotExist) {
+			t.Errorf("%s %s: expecting host not exist error, got %v", test.op, test.path, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package os
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// pipeSize is the number of bytes that can be written to a pipe before writes
+// block waiting for a reader.
+const pipeSize = 65536
+
+type pipe struct {
+	node
+	pmu         sync.Mutex
+	cond        sync.Cond
+	buf         []byte
+	readClosed  bool
+	writeClosed bool
+}
+
+// Pipe returns a connected pair of Files; reads from r return bytes written to
+// w.
+//
+// Reads block until data is available, returning io.EOF once the writer is
+// closed and the buffer drained. Writes block while the buffer is full, and
+// fail with ErrBrokenPipe once the reader is closed.
+func (f *FS) Pipe() (*File, *File, error) {
+	c := f.getCred()
+	now := f.now()
+	p := &pipe{
+		node: node{
+			tree:    f.tree,
+			ino:     f.newIno(),
+			mode:    os.ModeNamedPipe | 0600,
+			nlink:   1,
+			uid:     c.uid,
+			gid:     c.gid,
+			atime:   now,
+			modTime: now,
+			ctime:   now,
+		},
+		buf: make([]byte, 0, pipeSize),
+	}
+	p.cond.L = &p.pmu
+	rfd, err := f.allocFd()
+	if err != nil {
+		return nil, nil, err
+	}
+	wfd, err := f.allocFd()
+	if err != nil {
+		f.releaseFd(rfd)
+		return nil, nil, err
+	}
+	r := &File{
+		p,
+		"|0",
+		"|0",
+		rfd,
+		O_RDONLY,
+		f,
+		pipeReader{pipeEnd{p}},
+	}
+	w := &File{
+		p,
+		"|1",
+		"|1",
+		wfd,
+		O_WRONLY,
+		f,
+		pipeWriter{pipeEnd{p}},
+	}
+	f.setFd(r)
+	f.setFd(w)
+	return r, w, nil
+}
+
+func (p *pipe) IsDir() bool {
+	return false
+}
+
+func (p *pipe) Size() int64 {
+	return 0
+}
+
+func (p *pipe) Sys() interface{} {
+	return p.stat(0, 0).sys()
+}
+
+type pipeEnd struct {
+	*pipe
+}
+
+func (pipeEnd) ReadAt(_ []byte, _ int64) (int, error) {
+	return 0, ErrInvalid
+}
+
+func (pipeEnd) Readdir(_ int) ([]os.FileInfo, error) {
+	return nil, ErrInvalid
+}
+
+func (pipeEnd) Readdirnames(_ int) ([]string, error) {
+	return nil, ErrInvalid
+}
+
+func (pipeEnd) Seek(_ int64, _ int) (int64, error) {
+	return 0, ErrInvalid
+}
+
+func (pipeEnd) WriteAt(_ []byte, _ int64) (int, error) {
+	return 0, ErrInvalid
+}
+
+type pipeReader struct {
+	pipeEnd
+}
+
+func (r pipeReader) Read(b []byte) (int, error) {
+	r.pmu.Lock()
+	defer r.pmu.Unlock()
+	for len(r.buf) == 0 {
+		if r.readClosed {
+			return 0, ErrClosed
+		}
+		if r.writeClosed {
+			return 0, io.EOF
+		}
+		r.cond.Wait()
+	}
+	n := copy(b, r.buf)
+	r.buf = r.buf[:copy(r.buf, r.buf[n:])]
+	r.cond.Broadcast()
+	return n, nil
+}
+
+func (pipeReader) Write(_ []byte) (int, error) {
+	return 0, ErrInvalid
+}
+
+func (r pipeReader) close() {
+	r.pmu.Lock()
+	defer r.pmu.Unlock()
+	r.readClosed = true
+	r.cond.Broadcast()
+}
+
+type pipeWriter struct {
+	pipeEnd
+}
+
+func (pipeWriter) Read(_ []byte) (int, error) {
+	return 0, ErrInvalid
+}
+
+func (w pipeWriter) Write(b []byte) (int, error) {
+	w.pmu.Lock()
+	defer w.pmu.Unlock()
+	var n int
+	for len(b) > 0 {
+		if w.writeClosed {
+			return n, ErrClosed
+		}
+		if w.readClosed {
+			return n, ErrBrokenPipe
+		}
+		if len(w.buf) == pipeSize {
+			w.cond.Wait()
+			continue
+		}
+		m := len(b)
+		if free := pipeSize - len(w.buf); m > free {
+			m = free
+		}
+		w.buf = append(w.buf, b[:m]...)
+		b = b[m:]
+		n += m
+		w.cond.Broadcast()
+	}
+	return n, nil
+}
+
+func (w pipeWriter) close() {
+	w.pmu.Lock()
+	defer w.pmu.Unlock()
+	w.writeClosed = true
+	w.cond.Broadcast()
+}
@@ -1,15 +1,309 @@
 package os
 
-type Process struct{}
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
 
-func FindProcess(_ int) (*Process, error) {
-	return nil, ErrUnsupported
+// Executable is a function that can be run as a process by StartProcess. The
+// returned value is used as the exit code of the process.
+type Executable func(*Proc) int
+
+// Proc is the view of a running process that is given to an Executable.
+//
+// The embedded FS shares the tree of the filesystem the process was started
+// on, but has its own working directory and environment.
+type Proc struct {
+	*FS
+	Args    []string
+	Files   []*File
+	signals chan os.Signal
+	killed  chan struct{}
+}
+
+func (p *Proc) file(n int) *File {
+	if n < len(p.Files) {
+		return p.Files[n]
+	}
+	return nil
+}
+
+// Stdin returns the first of the files given to the process, or nil.
+func (p *Proc) Stdin() *File {
+	return p.file(0)
+}
+
+// Stdout returns the second of the files given to the process, or nil.
+func (p *Proc) Stdout() *File {
+	return p.file(1)
+}
+
+// Stderr returns the third of the files given to the process, or nil.
+func (p *Proc) Stderr() *File {
+	return p.file(2)
+}
+
+// Signals returns a channel on which signals sent to the process with
+// Process.Signal are received.
+func (p *Proc) Signals() <-chan os.Signal {
+	return p.signals
+}
+
+// Killed returns a channel that is closed when the process is killed.
+//
+// As a goroutine cannot be forcibly stopped, an Executable should return
+// promptly once this channel is closed; its exit code will be ignored.
+func (p *Proc) Killed() <-chan struct{} {
+	return p.killed
+}
+
+type ProcAttr struct {
+	Dir   string
+	Env   []string
+	Files []*File
+	Sys   interface{}
+}
+
+type Process struct {
+	Pid      int
+	tree     *tree
+	signals  chan os.Signal
+	killed   chan struct{}
+	done     chan struct{}
+	mu       sync.Mutex
+	state    *ProcessState
+	released bool
+}
+
+func (t *tree) newPid() int {
+	t.procMu.Lock()
+	defer t.procMu.Unlock()
+	t.lastPid++
+	return t.lastPid
+}
+
+func (f *FS) FindProcess(pid int) (*Process, error) {
+	f.procMu.Lock()
+	defer f.procMu.Unlock()
+	p, ok := f.procs[pid]
+	if !ok {
+		return nil, ErrProcessDone
+	}
+	return p, nil
+}
+
+func (f *FS) Getpid() int {
+	return f.pid
+}
+
+func (f *FS) Getppid() int {
+	return f.ppid
+}
+
+// StartProcess starts the Executable at the given path in a new goroutine.
+//
+// The process is given its own view of the filesystem, with the working
+// directory set to attr.Dir and the environment set to attr.Env, each
+// defaulting to that of the filesystem.
+func (f *FS) StartProcess(name string, argv []string, attr *ProcAttr) (*Process, error) {
+	if attr == nil {
+		attr = new(ProcAttr)
+	}
+	v := f.view()
+	if attr.Dir != "" {
+		d, err := v.navigateTo(attr.Dir, v.cred)
+		if err != nil {
+			return nil, &PathError{
+				"chdir",
+				attr.Dir,
+				err,
+			}
+		}
+		v.cwd = d
+	}
+	if attr.Env != nil {
+		v.env = newEnviron(attr.Env)
+	}
+	fi, err := v.getFile(name, true)
+	var fn Executable
+	if err == nil {
+		if b, ok := fi.(*bfile); !ok || !b.checkAccess(v.cred, permExecute) {
+			err = ErrPermission
+		} else if fn = b.program; fn == nil {
+			err = ErrExecFormat
+		}
+	}
+	if err != nil {
+		return nil, &PathError{
+			"fork/exec",
+			name,
+			err,
+		}
+	}
+	p := &Process{
+		Pid:     v.pid,
+		tree:    f.tree,
+		signals: make(chan os.Signal, 8),
+		killed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	f.procMu.Lock()
+	f.procs[p.Pid] = p
+	f.procMu.Unlock()
+	go p.run(fn, &Proc{
+		FS:      v,
+		Args:    append([]string{}, argv...),
+		Files:   append([]*File{}, attr.Files...),
+		signals: p.signals,
+		killed:  p.killed,
+	})
+	return p, nil
+}
+
+func (p *Process) run(fn Executable, proc *Proc) {
+	code := 2
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(exitCode); ok {
+				code = int(e)
+			} else if stderr := proc.Stderr(); stderr != nil {
+				fmt.Fprintf(stderr, "panic: %v\n", r)
+			}
+		}
+		p.finish(&ProcessState{
+			pid:  p.Pid,
+			code: code,
+		})
+	}()
+	code = fn(proc)
+}
+
+// finish sets the state of the process, returning false if it was already
+// set.
+func (p *Process) finish(state *ProcessState) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state != nil {
+		return false
+	}
+	p.state = state
+	close(p.done)
+	p.tree.procMu.Lock()
+	delete(p.tree.procs, p.Pid)
+	p.tree.procMu.Unlock()
+	return true
+}
+
+func (p *Process) Kill() error {
+	return p.Signal(os.Kill)
+}
+
+func (p *Process) Release() error {
+	if p == nil {
+		return ErrInvalid
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.released = true
+	return nil
+}
+
+func (p *Process) isReleased() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.released
+}
+
+// Signal sends a signal to the process. The os.Kill signal stops the process
+// immediately, closing the channel returned by Proc.Killed; all other signals
+// are delivered to the channel returned by Proc.Signals, being dropped if the
+// process is not keeping up with them.
+func (p *Process) Signal(sig os.Signal) error {
+	if p == nil || p.isReleased() {
+		return ErrInvalid
+	}
+	if sig == os.Kill {
+		if !p.finish(&ProcessState{
+			pid:    p.Pid,
+			code:   -1,
+			signal: sig,
+		}) {
+			return ErrProcessDone
+		}
+		close(p.killed)
+		return nil
+	}
+	select {
+	case <-p.done:
+		return ErrProcessDone
+	default:
+	}
+	select {
+	case p.signals <- sig:
+	default:
+	}
+	return nil
+}
+
+func (p *Process) Wait() (*ProcessState, error) {
+	if p == nil || p.isReleased() {
+		return nil, ErrInvalid
+	}
+	<-p.done
+	return p.state, nil
+}
+
+type ProcessState struct {
+	pid    int
+	code   int
+	signal os.Signal
+}
+
+// ExitCode returns the exit code of the process, or -1 if it was killed.
+func (p *ProcessState) ExitCode() int {
+	if p == nil {
+		return -1
+	}
+	return p.code
+}
+
+func (p *ProcessState) Exited() bool {
+	return p.signal == nil
+}
+
+func (p *ProcessState) Pid() int {
+	return p.pid
 }
 
-func StartProcess(name string, _ []string, _ interface{}) (*Process, error) {
-	return nil, &PathError{
-		"fork/exec",
-		name,
-		ErrUnsupported,
+func (p *ProcessState) String() string {
+	if p == nil {
+		return "<nil>"
 	}
+	if p.signal != nil {
+		return "signal: " + p.signal.String()
+	}
+	return "exit status " + strconv.Itoa(p.code)
+}
+
+func (p *ProcessState) Success() bool {
+	return p.signal == nil && p.code == 0
+}
+
+func (p *ProcessState) Sys() interface{} {
+	return nil
+}
+
+func (p *ProcessState) SysUsage() interface{} {
+	return nil
+}
+
+func (p *ProcessState) SystemTime() time.Duration {
+	return 0
+}
+
+func (p *ProcessState) UserTime() time.Duration {
+	return 0
 }
@@ -0,0 +1,135 @@
+package os
+
+import (
+	"math"
+	"sync"
+)
+
+// blockSize is the block size reported by Statfs.
+const blockSize = 4096
+
+type quota struct {
+	mu       sync.Mutex
+	maxBytes int64
+	maxNodes int64
+	bytes    int64
+	nodes    int64
+}
+
+// SetQuota limits the storage used by the files in the filesystem to the
+// given number of bytes, and the number of files, directories and symbolic
+// links to the given number of nodes. A limit of zero or less removes that
+// limit.
+//
+// Storage is allocated to files in blocks of 4096 bytes as they are written,
+// so the holes in sparse files use none.
+//
+// Operations that would exceed a limit fail with ErrNoSpace. Lowering a limit
+// below the current usage does not remove anything from the filesystem.
+func (f *FS) SetQuota(bytes, nodes int64) {
+	f.quota.mu.Lock()
+	defer f.quota.mu.Unlock()
+	f.quota.maxBytes = bytes
+	f.quota.maxNodes = nodes
+}
+
+// limits must be called with the quota lock held
+func (q *quota) limits() (int64, int64) {
+	maxBytes, maxNodes := q.maxBytes, q.maxNodes
+	if maxBytes <= 0 {
+		maxBytes = math.MaxInt64
+	}
+	if maxNodes <= 0 {
+		maxNodes = math.MaxInt64
+	}
+	return maxBytes, maxNodes
+}
+
+// reserve adds to the usage of the filesystem, or returns ErrNoSpace, leaving
+// the usage unchanged, if that would exceed a limit.
+func (q *quota) reserve(bytes, nodes int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	maxBytes, maxNodes := q.limits()
+	if bytes > maxBytes-q.bytes || nodes > maxNodes-q.nodes {
+		return ErrNoSpace
+	}
+	q.bytes += bytes
+	q.nodes += nodes
+	return nil
+}
+
+// reserveUpTo adds as many of the given bytes to the usage of the filesystem
+// as are available, returning the number added.
+func (q *quota) reserveUpTo(bytes int64) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	maxBytes, _ := q.limits()
+	if free := maxBytes - q.bytes; bytes > free {
+		bytes = free
+	}
+	if bytes < 0 {
+		bytes = 0
+	}
+	q.bytes += bytes
+	return bytes
+}
+
+// add changes the usage of the filesystem without checking the limits.
+func (q *quota) add(bytes, nodes int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.bytes += bytes
+	q.nodes += nodes
+}
+
+func (q *quota) set(bytes, nodes int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.bytes = bytes
+	q.nodes = nodes
+}
+
+// Statfs_t describes the capacity and usage of a filesystem.
+type Statfs_t struct {
+	Bsize  int64
+	Blocks uint64
+	Bfree  uint64
+	Bavail uint64
+	Files  uint64
+	Ffree  uint64
+}
+
+// Statfs fills buf with the capacity and usage of the filesystem containing
+// the given path. Unlimited filesystems report the largest possible capacity.
+func (f *FS) Statfs(p string, buf *Statfs_t) error {
+	if _, err := f.getFile(p, true); err != nil {
+		return &PathError{
+			"statfs",
+			p,
+			err,
+		}
+	}
+	f.quota.mu.Lock()
+	defer f.quota.mu.Unlock()
+	maxBytes, maxNodes := f.quota.limits()
+	blocks := maxBytes / blockSize
+	used := (f.quota.bytes + blockSize - 1) / blockSize
+	free := blocks - used
+	if free < 0 {
+		free = 0
+	}
+	ffree := maxNodes - f.quota.nodes
+	if ffree < 0 {
+		ffree = 0
+	}
+	*buf = Statfs_t{
+		Bsize:  blockSize,
+		Blocks: uint64(blocks),
+		Bfree:  uint64(free),
+		Bavail: uint64(free),
+		Files:  uint64(maxNodes),
+		Ffree:  uint64(ffree),
+	}
+	return nil
+}
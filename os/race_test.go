@@ -0,0 +1,170 @@
+package os
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+const (
+	stressGoroutines = 8
+	stressIterations = 200
+)
+
+// stress runs fn concurrently on a number of goroutines, passing the index of
+// each goroutine and iteration.
+func stress(fn func(g, i int)) {
+	var wg sync.WaitGroup
+	for g := 0; g < stressGoroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < stressIterations; i++ {
+				fn(g, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestStressTree(t *testing.T) {
+	f := New()
+	f.MkdirAll("/a/b", 0777)
+	f.MkdirAll("/c/d", 0777)
+	stress(func(g, i int) {
+		name := fmt.Sprintf("/a/b/f%d-%d", g, i%5)
+		if fl, err := f.OpenFile(name, O_RDWR|O_CREATE, 0666); err == nil {
+			fl.WriteString("hello")
+			fl.Seek(0, io.SeekStart)
+			fl.Read(make([]byte, 5))
+			fl.Close()
+		}
+		f.Stat(name)
+		f.Rename("/a/b", "/c/d/b")
+		f.Rename("/c/d/b", "/a/b")
+		f.Getwd()
+		f.Chmod(name, 0644)
+		f.Remove(name)
+		f.MkdirAll(fmt.Sprintf("/x/%d/%d", g, i), 0777)
+		f.RemoveAll("/x")
+		f.WriteString("/y/z", "data")
+		if d, err := f.Open("/a/b"); err == nil {
+			fis, _ := d.Readdir(-1)
+			for _, fi := range fis {
+				fi.Name()
+				fi.Mode()
+				fi.Size()
+			}
+			d.Close()
+		}
+	})
+	if _, err := f.Stat("/a/b"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStressSharedFile(t *testing.T) {
+	f := New()
+	f.WriteBytes("/file", 0666, make([]byte, stressGoroutines*stressIterations))
+	fl, err := f.OpenFile("/file", O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.Close()
+	var (
+		mu    sync.Mutex
+		total int
+	)
+	stress(func(g, i int) {
+		var buf [1]byte
+		switch g % 4 {
+		case 0:
+			n, _ := fl.Read(buf[:])
+			mu.Lock()
+			total += n
+			mu.Unlock()
+		case 1:
+			fl.Seek(0, io.SeekCurrent)
+		case 2:
+			fl.WriteAt([]byte{byte(i)}, int64(i))
+		case 3:
+			fl.ReadAt(buf[:], int64(i))
+			fl.Stat()
+		}
+	})
+	if pos, _ := fl.Seek(0, io.SeekCurrent); int(pos) != total {
+		t.Fatalf("expecting offset %d, got %d", total, pos)
+	}
+}
+
+func TestStressSharedDir(t *testing.T) {
+	f := New()
+	for i := 0; i < stressGoroutines*stressIterations; i++ {
+		f.WriteBytes(fmt.Sprintf("/dir/%d", i), 0666, nil)
+	}
+	d, err := f.Open("/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	var (
+		mu    sync.Mutex
+		names = make(map[string]bool)
+	)
+	stress(func(_, _ int) {
+		ns, _ := d.Readdirnames(1)
+		mu.Lock()
+		for _, n := range ns {
+			if names[n] {
+				t.Errorf("name %q returned twice", n)
+			}
+			names[n] = true
+		}
+		mu.Unlock()
+	})
+	if len(names) != stressGoroutines*stressIterations {
+		t.Fatalf("expecting %d names, got %d", stressGoroutines*stressIterations, len(names))
+	}
+}
+
+func TestStressCloseRead(t *testing.T) {
+	f := New()
+	for i := 0; i < stressIterations; i++ {
+		r, w, err := f.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error)
+		go func() {
+			_, err := r.Read(make([]byte, 1))
+			done <- err
+		}()
+		r.Close()
+		if err := <-done; err == nil {
+			t.Fatal("expecting error reading from closed pipe")
+		}
+		w.Close()
+	}
+	f.WriteBytes("/file", 0666, make([]byte, 1024))
+	stress(func(g, _ int) {
+		fl, err := f.Open("/file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		done := make(chan struct{})
+		go func() {
+			fl.Read(make([]byte, 16))
+			fl.Stat()
+			fl.Fd()
+			close(done)
+		}()
+		fl.Close()
+		<-done
+		if err := fl.Close(); !errors.Is(err, ErrClosed) {
+			t.Errorf("expecting ErrClosed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package os
+
+import "os"
+
+// Snapshot is an immutable record of the state of a filesystem, created with
+// FS.Snapshot and applied with FS.Restore.
+//
+// File contents are shared between a filesystem and its snapshots, only being
+// copied when written to, so taking and restoring snapshots is cheap.
+type Snapshot struct {
+	root *directory
+	cwd  *directory
+}
+
+// Snapshot records the current contents, metadata and working directory of the
+// filesystem.
+func (f *FS) Snapshot() *Snapshot {
+	f.root.load()
+	f.renameMu.Lock()
+	defer f.renameMu.Unlock()
+	nodes := make(map[os.FileInfo]os.FileInfo)
+	root := f.root.clone(nil, nil, nodes).(*directory)
+	cwd, ok := nodes[f.getCwd()].(*directory)
+	if !ok {
+		cwd = root
+	}
+	return &Snapshot{
+		root: root,
+		cwd:  cwd,
+	}
+}
+
+// Restore returns the filesystem to the state recorded in the snapshot, which
+// may have been taken from any filesystem.
+//
+// Files that are open when Restore is called no longer refer to the
+// filesystem.
+func (f *FS) Restore(s *Snapshot) {
+	f.renameMu.Lock()
+	defer f.renameMu.Unlock()
+	nodes := make(map[os.FileInfo]os.FileInfo)
+	root := s.root.clone(f.tree, nil, nodes).(*directory)
+	var size int64
+	for _, fi := range nodes {
+		if b, ok := fi.(*bfile); ok {
+			size += b.allocated()
+		}
+	}
+	f.quota.set(size, int64(len(nodes)))
+	f.root.mu.Lock()
+	root.copyNode(&f.root.node)
+	f.root.Contents = root.Contents
+	f.root.keys = root.keys
+	for _, fi := range f.root.Contents {
+		if d, ok := fi.(*directory); ok {
+			d.mu.Lock()
+			d.parent = f.root
+			d.mu.Unlock()
+		}
+	}
+	f.root.mu.Unlock()
+	cwd, ok := nodes[s.cwd].(*directory)
+	if !ok || cwd == root {
+		cwd = f.root
+	}
+	f.mu.Lock()
+	f.cwd = cwd
+	f.mu.Unlock()
+}
+
+type cloner interface {
+	clone(*tree, *directory, map[os.FileInfo]os.FileInfo) os.FileInfo
+}
+
+// copyNode copies the metadata of the node, other than the parent, to m.
+//
+// Must be called with the node lock held.
+func (n *node) copyNode(m *node) {
+	m.ino = n.ino
+	m.mode = n.mode
+	m.atime = n.atime
+	m.modTime = n.modTime
+	m.ctime = n.ctime
+	m.name = n.name
+	m.nlink = n.nlink
+	m.uid = n.uid
+	m.gid = n.gid
+}
+
+// clone creates a copy of the directory and all of its descendants, belonging
+// to the tree t, recording each copied node in nodes so that hard links are
+// preserved. A nil parent creates a root directory.
+func (d *directory) clone(t *tree, parent *directory, nodes map[os.FileInfo]os.FileInfo) os.FileInfo {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	e := &directory{
+		Contents: make(map[string]os.FileInfo, len(d.Contents)),
+		lower:    d.lower,
+	}
+	d.copyNode(&e.node)
+	e.tree = t
+	if parent == nil {
+		parent = e
+	}
+	e.parent = parent
+	nodes[d] = e
+	for name, fi := range d.Contents {
+		c, ok := nodes[fi]
+		if !ok {
+			c = fi.(cloner).clone(t, e, nodes)
+		}
+		e.Contents[name] = c
+	}
+	if t != nil {
+		p := t.getPersonality()
+		for name := range e.Contents {
+			e.index(p, name)
+		}
+	}
+	return e
+}
+
+func (f *bfile) clone(t *tree, parent *directory, nodes map[os.FileInfo]os.FileInfo) os.FileInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data.shared = true
+	g := &bfile{
+		data:    f.data,
+		program: f.program,
+		lower:   f.lower,
+	}
+	f.copyNode(&g.node)
+	g.tree = t
+	g.parent = parent
+	nodes[f] = g
+	return g
+}
+
+func (l *symlink) clone(t *tree, parent *directory, nodes map[os.FileInfo]os.FileInfo) os.FileInfo {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	m := &symlink{
+		target: l.target,
+	}
+	l.copyNode(&m.node)
+	m.tree = t
+	m.parent = parent
+	nodes[l] = m
+	return m
+}
@@ -0,0 +1,176 @@
+package os
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// AtimeMode determines when the access times of files are updated.
+type AtimeMode int
+
+const (
+	// Relatime updates the access time of a file when it is read if the
+	// previous access time is earlier than the modification or change time,
+	// or is over a day old.
+	Relatime AtimeMode = iota
+	// Strictatime updates the access time of a file whenever it is read.
+	Strictatime
+	// Noatime never updates the access time of a file.
+	Noatime
+)
+
+// SetAtimeMode sets when the access times of files in the filesystem are
+// updated. The default is Relatime.
+func (f *FS) SetAtimeMode(mode AtimeMode) {
+	f.optMu.Lock()
+	defer f.optMu.Unlock()
+	f.atime = mode
+}
+
+func (t *tree) getAtimeMode() AtimeMode {
+	t.optMu.RLock()
+	defer t.optMu.RUnlock()
+	return t.atime
+}
+
+func (t *tree) now() time.Time {
+	t.optMu.RLock()
+	c := t.clock
+	t.optMu.RUnlock()
+	return clockNow(c)
+}
+
+func (t *tree) newIno() uint64 {
+	return atomic.AddUint64(&t.lastIno, 1)
+}
+
+// accessed updates the access time of the node, according to the AtimeMode of
+// the filesystem.
+//
+// Must not be called with the node lock held.
+func (n *node) accessed() {
+	mode := n.tree.getAtimeMode()
+	if mode == Noatime {
+		return
+	}
+	now := n.tree.now()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if mode == Relatime && n.atime.After(n.modTime) && n.atime.After(n.ctime) && now.Sub(n.atime) < 24*time.Hour {
+		return
+	}
+	n.atime = now
+}
+
+// modified sets the modification and change times of the node to now.
+//
+// Must be called with the node lock held.
+func (n *node) modified() {
+	n.modTime = n.tree.now()
+	n.ctime = n.modTime
+}
+
+// changed sets the change time of the node to now.
+//
+// Must be called with the node lock held.
+func (n *node) changed() {
+	n.ctime = n.tree.now()
+}
+
+// Timespec is a time as a number of seconds and nanoseconds since the Unix
+// epoch.
+type Timespec struct {
+	Sec  int64
+	Nsec int64
+}
+
+func timespec(t time.Time) Timespec {
+	return Timespec{
+		Sec:  t.Unix(),
+		Nsec: int64(t.Nanosecond()),
+	}
+}
+
+// Unix returns the time as seconds and nanoseconds since the Unix epoch.
+func (t Timespec) Unix() (int64, int64) {
+	return t.Sec, t.Nsec
+}
+
+// Stat_t holds the values returned by the Sys method of the os.FileInfo values
+// returned by the package, following the layout of syscall.Stat_t.
+//
+// On Linux and macOS, Sys returns these values as a *syscall.Stat_t; on other
+// systems it returns a *Stat_t.
+type Stat_t struct {
+	Dev     uint64
+	Ino     uint64
+	Nlink   uint64
+	Mode    uint32
+	Uid     uint32
+	Gid     uint32
+	Rdev    uint64
+	Size    int64
+	Blksize int64
+	Blocks  int64
+	Atim    Timespec
+	Mtim    Timespec
+	Ctim    Timespec
+}
+
+const (
+	unixIFIFO = 0010000
+	unixIFDIR = 0040000
+	unixIFREG = 0100000
+	unixIFLNK = 0120000
+	unixISUID = 0004000
+	unixISGID = 0002000
+	unixISVTX = 0001000
+)
+
+// unixMode converts an os.FileMode to the mode bits of a Stat_t.
+func unixMode(mode os.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	switch {
+	case mode.IsDir():
+		m |= unixIFDIR
+	case mode&os.ModeSymlink != 0:
+		m |= unixIFLNK
+	case mode&os.ModeNamedPipe != 0:
+		m |= unixIFIFO
+	default:
+		m |= unixIFREG
+	}
+	if mode&os.ModeSetuid != 0 {
+		m |= unixISUID
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= unixISGID
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= unixISVTX
+	}
+	return m
+}
+
+// stat returns the Stat_t of a node with the given size, and number of bytes
+// of allocated storage.
+//
+// Must not be called with the node lock held.
+func (n *node) stat(size, allocated int64) *Stat_t {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return &Stat_t{
+		Ino:     n.ino,
+		Nlink:   n.nlink,
+		Mode:    unixMode(n.mode),
+		Uid:     uint32(n.uid),
+		Gid:     uint32(n.gid),
+		Size:    size,
+		Blksize: blockSize,
+		Blocks:  (allocated + 511) / 512,
+		Atim:    timespec(n.atime),
+		Mtim:    timespec(n.modTime),
+		Ctim:    timespec(n.ctime),
+	}
+}
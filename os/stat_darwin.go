@@ -0,0 +1,9 @@
+package os
+
+import "syscall"
+
+func (s *Stat_t) setTimes(st *syscall.Stat_t) {
+	st.Atimespec = s.Atim.sys()
+	st.Mtimespec = s.Mtim.sys()
+	st.Ctimespec = s.Ctim.sys()
+}
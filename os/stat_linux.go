@@ -0,0 +1,9 @@
+package os
+
+import "syscall"
+
+func (s *Stat_t) setTimes(st *syscall.Stat_t) {
+	st.Atim = s.Atim.sys()
+	st.Mtim = s.Mtim.sys()
+	st.Ctim = s.Ctim.sys()
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package os
+
+func (s *Stat_t) sys() interface{} {
+	return s
+}
@@ -0,0 +1,40 @@
+//go:build linux || darwin
+
+package os
+
+import "syscall"
+
+type integer interface {
+	~int16 | ~uint16 | ~int32 | ~uint32 | ~int64 | ~uint64
+}
+
+// setInt sets an integer field of a syscall type, whose size differs between
+// platforms.
+func setInt[T, U integer](field *T, v U) {
+	*field = T(v)
+}
+
+func (t Timespec) sys() syscall.Timespec {
+	var ts syscall.Timespec
+	setInt(&ts.Sec, t.Sec)
+	setInt(&ts.Nsec, t.Nsec)
+	return ts
+}
+
+// sys converts the Stat_t to a *syscall.Stat_t, allowing code that
+// type-asserts on the Sys method of an os.FileInfo to keep working.
+func (s *Stat_t) sys() interface{} {
+	var st syscall.Stat_t
+	setInt(&st.Dev, s.Dev)
+	setInt(&st.Ino, s.Ino)
+	setInt(&st.Nlink, s.Nlink)
+	setInt(&st.Mode, s.Mode)
+	setInt(&st.Uid, s.Uid)
+	setInt(&st.Gid, s.Gid)
+	setInt(&st.Rdev, s.Rdev)
+	setInt(&st.Size, s.Size)
+	setInt(&st.Blksize, s.Blksize)
+	setInt(&st.Blocks, s.Blocks)
+	s.setTimes(&st)
+	return &st
+}
@@ -3,59 +3,98 @@ package os
 import (
 	"os"
 	"path"
-	"reflect"
 	"strings"
-	"time"
 	"unsafe"
 )
 
-func WriteBytes(p string, perm os.FileMode, data []byte) {
+func (f *FS) WriteBytes(p string, perm os.FileMode, data []byte) {
+	f.writeFile(p, perm, &bfile{data: newChunks(data, true)})
+}
+
+// WriteExecutable creates a file at the given path which, when started with
+// StartProcess, runs the given function.
+func (f *FS) WriteExecutable(p string, perm os.FileMode, fn Executable) {
+	f.writeFile(p, perm, &bfile{program: fn})
+}
+
+func (f *FS) writeFile(p string, perm os.FileMode, b *bfile) {
 	var filename string
 	p, filename = path.Split(path.Clean(p))
-	if len(p) == 0 {
-		return
-	}
-	d := cwd
-	if p[0] == '/' {
-		d = root
+	d := f.getCwd()
+	c := f.getCred()
+	if len(p) > 0 && p[0] == '/' {
+		d = f.root
 		p = p[1:]
 	}
 	for _, dir := range strings.Split(p, "/") {
 		switch dir {
 		case "", ".":
 		case "..":
-			d = d.parent
+			d = d.getParent()
 		default:
-			fi, ok := d.Contents[dir]
+			d.load()
+			d.mu.Lock()
+			_, fi, ok := d.lookup(dir)
 			if !ok || !fi.IsDir() {
+				if ok {
+					d.removeLocked(dir, true, cred{})
+				}
+				dir = d.tree.storeName(dir)
+				now := d.tree.now()
 				e := &directory{
-					node{
-						os.ModeDir | 0777,
-						time.Now(),
-						dir,
-						d,
+					node: node{
+						tree:    d.tree,
+						ino:     d.tree.newIno(),
+						mode:    os.ModeDir | 0777,
+						nlink:   1,
+						uid:     c.uid,
+						gid:     c.gid,
+						atime:   now,
+						modTime: now,
+						ctime:   now,
+						name:    dir,
+						parent:  d,
 					},
-					make(map[string]os.FileInfo),
+					Contents: make(map[string]os.FileInfo),
 				}
-				d.Contents[dir] = e
-				d = e
-			} else {
-				d = fi.(*directory)
+				d.set(dir, e)
+				d.modified()
+				d.tree.quota.add(0, 1)
+				fi = e
 			}
+			d.mu.Unlock()
+			d = fi.(*directory)
 		}
 	}
-	d.Contents[filename] = &file{
-		node{
-			perm,
-			time.Now(),
-			filename,
-			d,
-		},
-		data,
-	}
+	d.load()
+	d.mu.Lock()
+	// replace any existing entry, including a whole directory tree,
+	// regardless of permissions
+	d.removeLocked(filename, true, cred{})
+	filename = d.tree.storeName(filename)
+	now := d.tree.now()
+	b.tree = d.tree
+	b.ino = d.tree.newIno()
+	b.mode = perm
+	b.nlink = 1
+	b.uid = c.uid
+	b.gid = c.gid
+	b.atime = now
+	b.modTime = now
+	b.ctime = now
+	b.name = filename
+	b.parent = d
+	d.set(filename, b)
+	d.modified()
+	d.tree.quota.add(b.data.allocated(), 1)
+	d.mu.Unlock()
 }
 
-func WriteString(p, data string) {
-	s := (*reflect.StringHeader)(unsafe.Pointer(&data))
-	WriteBytes(p, 0400, *(*[]byte)(unsafe.Pointer(&reflect.SliceHeader{s.Data, s.Len, s.Len})))
+// WriteString creates a read-only file at the given path containing the
+// string, without copying it. The contents are copied if the file is later
+// written to.
+func (f *FS) WriteString(p, data string) {
+	f.writeFile(p, 0400, &bfile{
+		data: newChunks(unsafe.Slice(unsafe.StringData(data), len(data)), false),
+	})
 }
@@ -0,0 +1,59 @@
+package os
+
+// cred contains the user and group IDs that operations on a filesystem are
+// performed as.
+type cred struct {
+	uid, gid int
+	groups   []int
+}
+
+func (c cred) inGroup(gid int) bool {
+	if c.gid == gid {
+		return true
+	}
+	for _, g := range c.groups {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCredentials sets the user and group IDs that subsequent operations on the
+// filesystem are performed as. New files and directories are owned by the uid
+// and gid.
+func (f *FS) SetCredentials(uid, gid int, groups ...int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cred = cred{
+		uid,
+		gid,
+		append([]int{}, groups...),
+	}
+}
+
+func (f *FS) getCred() cred {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.cred
+}
+
+func (f *FS) Getegid() int {
+	return f.getCred().gid
+}
+
+func (f *FS) Geteuid() int {
+	return f.getCred().uid
+}
+
+func (f *FS) Getgid() int {
+	return f.getCred().gid
+}
+
+func (f *FS) Getgroups() ([]int, error) {
+	return append([]int{}, f.getCred().groups...), nil
+}
+
+func (f *FS) Getuid() int {
+	return f.getCred().uid
+}
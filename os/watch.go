@@ -0,0 +1,236 @@
+package os
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// watchQueueSize is the number of events that can be queued for a Watcher
+// before further events are dropped.
+const watchQueueSize = 1024
+
+// EventOp describes the change reported by an Event.
+type EventOp uint32
+
+const (
+	EventCreate EventOp = 1 << iota
+	EventWrite
+	EventRemove
+	EventRename
+	EventChmod
+	// EventOverflow is sent when events have been dropped because they
+	// were not being received quickly enough.
+	EventOverflow
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case EventCreate:
+		return "CREATE"
+	case EventWrite:
+		return "WRITE"
+	case EventRemove:
+		return "REMOVE"
+	case EventRename:
+		return "RENAME"
+	case EventChmod:
+		return "CHMOD"
+	case EventOverflow:
+		return "OVERFLOW"
+	}
+	return "UNKNOWN"
+}
+
+// Event is a change to a watched path. Renames are reported as an EventRename
+// for the old path and an EventCreate for the new path.
+type Event struct {
+	Op   EventOp
+	Path string
+}
+
+func (e Event) String() string {
+	return e.Op.String() + " " + e.Path
+}
+
+// Watcher receives the events for a watched path, as created by FS.Watch.
+type Watcher struct {
+	tree      *tree
+	path      string
+	recursive bool
+	events    chan Event
+	ready     chan struct{}
+	done      chan struct{}
+
+	mu       sync.Mutex
+	queue    []Event
+	overflow bool
+	sending  bool
+}
+
+// Watch starts watching the given path, which must exist, for changes. Changes
+// to the path itself and to the entries of the directory at the path are
+// reported, as are the changes to all descendants of the directory if
+// recursive is true. When an ancestor of the path is removed or renamed, an
+// EventRemove or EventRename is sent for the path itself.
+//
+// Events are queued without blocking the operations that cause them. When the
+// queue is full, events are dropped and a single EventOverflow is queued.
+func (f *FS) Watch(p string, recursive bool) (*Watcher, error) {
+	if _, err := f.getFile(p, true); err != nil {
+		return nil, &PathError{
+			"watch",
+			p,
+			err,
+		}
+	}
+	w := &Watcher{
+		tree:      f.tree,
+		path:      f.abs(p),
+		recursive: recursive,
+		events:    make(chan Event),
+		ready:     make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	f.watchMu.Lock()
+	f.watchers[w] = struct{}{}
+	f.watchMu.Unlock()
+	go w.run()
+	return w, nil
+}
+
+// Events returns the channel on which events are received, which is closed
+// after the Watcher is closed.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Close stops the watcher, discarding any queued events.
+func (w *Watcher) Close() error {
+	w.tree.watchMu.Lock()
+	_, ok := w.tree.watchers[w]
+	delete(w.tree.watchers, w)
+	w.tree.watchMu.Unlock()
+	if !ok {
+		return ErrClosed
+	}
+	close(w.done)
+	return nil
+}
+
+func (w *Watcher) run() {
+	defer close(w.events)
+	for {
+		select {
+		case <-w.ready:
+		case <-w.done:
+			return
+		}
+		for {
+			w.mu.Lock()
+			if len(w.queue) == 0 {
+				w.mu.Unlock()
+				break
+			}
+			e := w.queue[0]
+			w.queue = w.queue[1:]
+			if e.Op == EventOverflow {
+				w.overflow = false
+			}
+			w.sending = true
+			w.mu.Unlock()
+			select {
+			case w.events <- e:
+			case <-w.done:
+				return
+			}
+			w.mu.Lock()
+			w.sending = false
+			w.mu.Unlock()
+		}
+	}
+}
+
+// matches returns true if an event for the given path should be sent to the
+// watcher, with paths compared according to the personality of the filesystem.
+func (w *Watcher) matches(p string, pers Personality) bool {
+	p, wpath := pers.key(p), pers.key(w.path)
+	if p == wpath || path.Dir(p) == wpath {
+		return true
+	}
+	if !w.recursive {
+		return false
+	}
+	if wpath == "/" {
+		return true
+	}
+	return strings.HasPrefix(p, wpath+"/")
+}
+
+// within returns true if the watched path is a descendant of the given path.
+func (w *Watcher) within(p string, pers Personality) bool {
+	return strings.HasPrefix(pers.key(w.path), pers.key(p)+"/")
+}
+
+func (w *Watcher) push(e Event) {
+	w.mu.Lock()
+	if w.overflow {
+		w.mu.Unlock()
+		return
+	}
+	n := len(w.queue)
+	if w.sending {
+		n++
+	}
+	if n >= watchQueueSize {
+		e = Event{Op: EventOverflow}
+		w.overflow = true
+	}
+	w.queue = append(w.queue, e)
+	w.mu.Unlock()
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+}
+
+// notify sends an event for the absolute path p to the matching watchers.
+func (t *tree) notify(op EventOp, p string) {
+	pers := t.getPersonality()
+	t.watchMu.RLock()
+	defer t.watchMu.RUnlock()
+	for w := range t.watchers {
+		if w.matches(p, pers) {
+			w.push(Event{
+				Op:   op,
+				Path: p,
+			})
+		} else if op&(EventRemove|EventRename) != 0 && w.within(p, pers) {
+			// the watched path has gone with its ancestor
+			w.push(Event{
+				Op:   op,
+				Path: w.path,
+			})
+		}
+	}
+}
+
+// notify sends an event for the path p, which is made absolute, to the
+// matching watchers.
+func (f *FS) notify(op EventOp, p string) {
+	f.watchMu.RLock()
+	l := len(f.watchers)
+	f.watchMu.RUnlock()
+	if l > 0 {
+		f.tree.notify(op, f.abs(p))
+	}
+}
+
+// notify sends an event for the file to the matching watchers. Files that
+// are not in the tree, such as pipes, produce no events.
+func (f *File) notify(op EventOp) {
+	if _, ok := f.fi.(*pipe); ok {
+		return
+	}
+	f.fs.tree.notify(op, f.path)
+}
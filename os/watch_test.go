@@ -0,0 +1,152 @@
+package os
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchEvents(t *testing.T) {
+	for n, test := range [...]struct {
+		path      string
+		recursive bool
+		op        func(*FS) error
+		events    []Event
+	}{
+		{
+			"/a", false,
+			func(f *FS) error { return f.Mkdir("/a/x", 0755) },
+			[]Event{{EventCreate, "/a/x"}},
+		},
+		{
+			"/a", false,
+			func(f *FS) error { return f.Mkdir("/a/b/x", 0755) },
+			nil,
+		},
+		{
+			"/a", true,
+			func(f *FS) error { return f.Mkdir("/a/b/x", 0755) },
+			[]Event{{EventCreate, "/a/b/x"}},
+		},
+		{
+			"/a", false,
+			func(f *FS) error { return f.Rename("/a/b", "/a/y") },
+			[]Event{{EventRename, "/a/b"}, {EventCreate, "/a/y"}},
+		},
+		{
+			"/a/b/c", false,
+			func(f *FS) error { return f.Remove("/a/b/c/file") },
+			[]Event{{EventRemove, "/a/b/c/file"}},
+		},
+		{
+			"/a/b/c", false,
+			func(f *FS) error { return f.RemoveAll("/a") },
+			[]Event{{EventRemove, "/a/b/c"}},
+		},
+		{
+			"/a/b/c/file", false,
+			func(f *FS) error { return f.Rename("/a/b", "/z") },
+			[]Event{{EventRename, "/a/b/c/file"}},
+		},
+		{
+			"/a/b", true,
+			func(f *FS) error { return f.Rename("/a", "/z") },
+			[]Event{{EventRename, "/a/b"}},
+		},
+		{
+			"/a/bc", false,
+			func(f *FS) error { return f.RemoveAll("/a/b") },
+			nil,
+		},
+	} {
+		f := New()
+		f.MkdirAll("/a/b/c", 0755)
+		f.MkdirAll("/a/bc", 0755)
+		f.WriteBytes("/a/b/c/file", 0644, nil)
+		w, err := f.Watch(test.path, test.recursive)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+		if err := test.op(f); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+		for _, e := range test.events {
+			select {
+			case got := <-w.Events():
+				if got != e {
+					t.Errorf("test %d: expecting event %s, got %s", n+1, e, got)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("test %d: timed out waiting for event %s", n+1, e)
+			}
+		}
+		select {
+		case got := <-w.Events():
+			t.Errorf("test %d: unexpected event %s", n+1, got)
+		case <-time.After(10 * time.Millisecond):
+		}
+		w.Close()
+	}
+}
+
+func TestWatchOverflow(t *testing.T) {
+	f := New()
+	f.Mkdir("/a", 0755)
+	w, err := f.Watch("/a", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for i := 0; i < watchQueueSize+10; i++ {
+		f.Chmod("/a", 0755)
+	}
+	for i := 0; i < watchQueueSize; i++ {
+		if e := <-w.Events(); e != (Event{EventChmod, "/a"}) {
+			t.Fatalf("event %d: expecting chmod event, got %s", i, e)
+		}
+	}
+	if e := <-w.Events(); e.Op != EventOverflow {
+		t.Fatalf("expecting overflow event, got %s", e)
+	}
+	f.Mkdir("/a/b", 0755)
+	select {
+	case e := <-w.Events():
+		if e != (Event{EventCreate, "/a/b"}) {
+			t.Errorf("expecting create event after overflow, got %s", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("timed out waiting for event after overflow")
+	}
+}
+
+func TestWatchClose(t *testing.T) {
+	f := New()
+	f.Mkdir("/a", 0755)
+	w, err := f.Watch("/a", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		f.Chmod("/a", 0755)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := w.Close(); err != ErrClosed {
+		t.Errorf("expecting ErrClosed on second close, got %v", err)
+	}
+	f.Mkdir("/a/b", 0755)
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case e, ok := <-w.Events():
+			if !ok {
+				return
+			}
+			if e.Op != EventChmod {
+				t.Errorf("unexpected event after close: %s", e)
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for events channel to close")
+		}
+	}
+}